@@ -0,0 +1,298 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/fieldalignment"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/reflectvaluecompare"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/sortslice"
+	"golang.org/x/tools/go/analysis/passes/unusedwrite"
+	"golang.org/x/tools/go/packages"
+)
+
+// KnownAnalyzers is the map of all the analyzers that can be referenced by
+// name in the Analyzers check.
+//
+// It contains the analyzers run by go vet and a few more that go vet doesn't
+// enable by default. A program embedding this package can add its own.
+var KnownAnalyzers = map[string]*analysis.Analyzer{}
+
+// Analyzers runs go/analysis analyzers on the packages modified by the change.
+//
+// Analyzers are either built in, see KnownAnalyzers, or bundled in a vettool
+// binary built with golang.org/x/tools/go/analysis/unitchecker that is run via
+// "go vet -vettool".
+type Analyzers struct {
+	// Analyzers is the list of built-in analyzers to run by name, e.g. "shadow".
+	Analyzers []string `yaml:"analyzers"`
+	// Vettool is the path to a binary built with unitchecker.Main. A relative
+	// path is relative to the repository root and a bare name is searched in
+	// PATH.
+	Vettool string `yaml:"vettool"`
+	// VettoolFlags are additional flags for the vettool, e.g. "-foo.strict".
+	VettoolFlags []string `yaml:"vettool_flags"`
+}
+
+// GetDescription implements Check.
+func (a *Analyzers) GetDescription() string {
+	return "runs go/analysis analyzers on modified packages"
+}
+
+// GetName implements Check.
+func (a *Analyzers) GetName() string {
+	return "analyzers"
+}
+
+// GetPrerequisites implements Check.
+func (a *Analyzers) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (a *Analyzers) Run(change scm.Change, options *Options) error {
+	var analyzers []*analysis.Analyzer
+	for _, name := range a.Analyzers {
+		an := KnownAnalyzers[name]
+		if an == nil {
+			return fmt.Errorf("analyzers: unknown analyzer %q", name)
+		}
+		analyzers = append(analyzers, an)
+	}
+	pkgs := change.Changed().Packages()
+	if len(pkgs) == 0 {
+		return nil
+	}
+	var diags []finding
+	if len(analyzers) != 0 {
+		d, err := runAnalyzers(change, options, analyzers, pkgs)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, d...)
+	}
+	if a.Vettool != "" {
+		d, err := a.runVettool(change, options, pkgs)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, d...)
+	}
+	return newFindingsError("analyzers failed:", changedFindings(change, diags))
+}
+
+// runVettool runs "go vet -vettool" and parses its json output.
+func (a *Analyzers) runVettool(change scm.Change, options *Options, pkgs []string) ([]finding, error) {
+	tool := a.Vettool
+	if !filepath.IsAbs(tool) {
+		if strings.ContainsRune(filepath.ToSlash(tool), '/') {
+			tool = filepath.Join(change.Repo().Root(), tool)
+		} else {
+			p, err := exec.LookPath(tool)
+			if err != nil {
+				return nil, fmt.Errorf("analyzers: vettool %q not found: %s", tool, err)
+			}
+			tool = p
+		}
+	}
+	args := append([]string{"go", "vet", "-vettool=" + tool, "-json"}, a.VettoolFlags...)
+	args = append(args, pkgs...)
+	out, exitCode, _, err := options.Capture(change.Repo(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s", strings.Join(args, " "), err)
+	}
+	// With -json, go vet only exits with non-zero when the packages fail to
+	// load or the tool fails.
+	if exitCode != 0 {
+		return nil, fmt.Errorf("%s failed:\n%s", strings.Join(args, " "), out)
+	}
+	diags, err := parseVetJSON(change, out)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s\n%s", strings.Join(args, " "), err, out)
+	}
+	return diags, nil
+}
+
+// Private stuff.
+
+func init() {
+	for _, a := range govetAnalyzers {
+		KnownAnalyzers[a.Name] = a
+	}
+	for _, a := range []*analysis.Analyzer{
+		deepequalerrors.Analyzer,
+		fieldalignment.Analyzer,
+		nilness.Analyzer,
+		reflectvaluecompare.Analyzer,
+		shadow.Analyzer,
+		sortslice.Analyzer,
+		unusedwrite.Analyzer,
+	} {
+		KnownAnalyzers[a.Name] = a
+	}
+}
+
+// runAnalyzers loads the packages pkgs, including their tests, and runs the
+// analyzers on them.
+//
+// It returns the sorted diagnostics in files inside the repository that are not
+// ignored. Diagnostics reported multiple times, as happens for files part of
+// both a package and its test variant, are returned once.
+func runAnalyzers(change scm.Change, options *Options, analyzers []*analysis.Analyzer, pkgs []string) ([]finding, error) {
+	options.LeaseRunToken()
+	defer options.ReturnRunToken()
+
+	cfg := &packages.Config{
+		Mode:  packages.LoadAllSyntax,
+		Dir:   change.Repo().Root(),
+		Env:   append(os.Environ(), "GOPATH="+change.Repo().GOPATH()),
+		Tests: true,
+	}
+	loaded, err := packages.Load(cfg, pkgs...)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	packages.Visit(loaded, nil, func(p *packages.Package) {
+		for _, e := range p.Errors {
+			errs = append(errs, e.Error())
+		}
+	})
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("failed to load packages:\n%s", strings.Join(errs, "\n"))
+	}
+	graph, err := checker.Analyze(analyzers, loaded, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []finding
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			return nil, fmt.Errorf("%s failed on %s: %s", act.Analyzer.Name, act.Package.PkgPath, act.Err)
+		}
+		fset := act.Package.Fset
+		for _, d := range act.Diagnostics {
+			pos := fset.Position(d.Pos)
+			diag := finding{
+				path:    repoRelPath(change, pos.Filename),
+				line:    pos.Line,
+				column:  pos.Column,
+				rule:    act.Analyzer.Name,
+				message: d.Message,
+			}
+			if diag.path == "" {
+				continue
+			}
+			for _, f := range d.SuggestedFixes {
+				fix := suggestedFix{message: f.Message}
+				for _, e := range f.TextEdits {
+					start := fset.Position(e.Pos)
+					end := start
+					if e.End.IsValid() {
+						end = fset.Position(e.End)
+					}
+					fix.edits = append(fix.edits, textEdit{repoRelPath(change, start.Filename), start.Offset, end.Offset, string(e.NewText)})
+				}
+				diag.fixes = append(diag.fixes, fix)
+			}
+			out = append(out, diag)
+		}
+	}
+	return sortFindings(out), nil
+}
+
+// vetJSONDiagnostic is a diagnostic as printed by "go vet -json".
+type vetJSONDiagnostic struct {
+	Posn           string `json:"posn"`
+	Message        string `json:"message"`
+	SuggestedFixes []struct {
+		Message string `json:"message"`
+		Edits   []struct {
+			Filename string `json:"filename"`
+			Start    int    `json:"start"`
+			End      int    `json:"end"`
+			New      string `json:"new"`
+		} `json:"edits"`
+	} `json:"suggested_fixes"`
+}
+
+// rePosn matches a "file:line:column" position; the file name may contain
+// ':'.
+var rePosn = regexp.MustCompile(`^(.+):(\d+):(\d+)$`)
+
+// parseVetJSON parses the output of "go vet -json".
+//
+// The output is a series of "# pkg" comment lines, each followed by a json
+// object of package ID : analyzer name : list of diagnostics, or an object
+// with an "error" key when the analyzer failed.
+func parseVetJSON(change scm.Change, out string) ([]finding, error) {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	var diags []finding
+	d := json.NewDecoder(strings.NewReader(strings.Join(lines, "\n")))
+	for {
+		var tree map[string]map[string]json.RawMessage
+		if err := d.Decode(&tree); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for _, analyzers := range tree {
+			for name, raw := range analyzers {
+				var failure struct {
+					Error string `json:"error"`
+				}
+				if json.Unmarshal(raw, &failure) == nil && failure.Error != "" {
+					return nil, fmt.Errorf("%s failed: %s", name, failure.Error)
+				}
+				var items []vetJSONDiagnostic
+				if err := json.Unmarshal(raw, &items); err != nil {
+					return nil, err
+				}
+				for _, item := range items {
+					m := rePosn.FindStringSubmatch(item.Posn)
+					if m == nil {
+						return nil, fmt.Errorf("invalid position %q", item.Posn)
+					}
+					diag := finding{path: repoRelPath(change, m[1]), rule: name, message: item.Message}
+					if diag.path == "" {
+						continue
+					}
+					diag.line, _ = strconv.Atoi(m[2])
+					diag.column, _ = strconv.Atoi(m[3])
+					for _, f := range item.SuggestedFixes {
+						fix := suggestedFix{message: f.Message}
+						for _, e := range f.Edits {
+							fix.edits = append(fix.edits, textEdit{repoRelPath(change, e.Filename), e.Start, e.End, e.New})
+						}
+						diag.fixes = append(diag.fixes, fix)
+					}
+					diags = append(diags, diag)
+				}
+			}
+		}
+	}
+	return sortFindings(diags), nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestAnalyzers(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go": "package foo\n\nfunc Foo(x int) int {\n\tif x > 0 {\n\t\tx := 1\n\t\treturn x\n\t}\n\treturn x\n}\n",
+	})
+	options := &Options{MaxDuration: 60}
+	expected := "analyzers failed:\n" +
+		"foo.go:5:3: declaration of \"x\" shadows declaration at line 3 (shadow)"
+	ut.AssertEqual(t, expected, (&Analyzers{Analyzers: []string{"shadow"}}).Run(change, options).Error())
+	ut.AssertEqual(t, nil, (&Analyzers{Analyzers: []string{"printf"}}).Run(change, options))
+	ut.AssertEqual(t, errors.New("analyzers: unknown analyzer \"foo\""), (&Analyzers{Analyzers: []string{"foo"}}).Run(change, options))
+}
+
+func TestParseVetJSON(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n"})
+	root := filepath.ToSlash(change.Repo().Root())
+	out := "# foo\n" +
+		"{\n" +
+		"\t\"foo\": {\n" +
+		"\t\t\"unusedresult\": [\n" +
+		"\t\t\t{\"posn\": \"" + root + "/foo.go:11:2\", \"message\": \"result of fmt.Sprintf call not used\"}\n" +
+		"\t\t],\n" +
+		"\t\t\"mycheck\": [\n" +
+		"\t\t\t{\"posn\": \"" + root + "/foo.go:3:1\", \"message\": \"use Bar\", \"suggested_fixes\": [\n" +
+		"\t\t\t\t{\"message\": \"Replace with Bar\", \"edits\": [{\"filename\": \"" + root + "/foo.go\", \"start\": 12, \"end\": 15, \"new\": \"Bar\"}]}\n" +
+		"\t\t\t]},\n" +
+		"\t\t\t{\"posn\": \"/elsewhere/bar.go:1:1\", \"message\": \"outside\"}\n" +
+		"\t\t]\n" +
+		"\t}\n" +
+		"}\n"
+	diags, err := parseVetJSON(change, out)
+	ut.AssertEqual(t, nil, err)
+	expected := []finding{
+		{
+			path: "foo.go", line: 3, column: 1, rule: "mycheck", message: "use Bar",
+			fixes: []suggestedFix{{"Replace with Bar", []textEdit{{"foo.go", 12, 15, "Bar"}}}},
+		},
+		{path: "foo.go", line: 11, column: 2, rule: "unusedresult", message: "result of fmt.Sprintf call not used"},
+	}
+	ut.AssertEqual(t, expected, diags)
+	ut.AssertEqual(t, "foo.go:3:1: use Bar (mycheck)\n  suggested fix: Replace with Bar", diags[0].String())
+
+	_, err = parseVetJSON(change, "{\"foo\": {\"mycheck\": {\"error\": \"boom\"}}}\n")
+	ut.AssertEqual(t, errors.New("mycheck failed: boom"), err)
+}
@@ -0,0 +1,258 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// BaselineFile is the name of the baseline file at the root of the
+// repository. It is meant to be committed.
+const BaselineFile = "pre-commit-go-baseline.yml"
+
+// Baseline is the set of existing findings that are grandfathered, so that
+// only new findings are reported.
+//
+// A finding is identified by a fingerprint of the check, the file, the message
+// and the surrounding code but not the line number, so the baseline survives
+// code moving around in the file.
+//
+// It is safe to use concurrently.
+type Baseline struct {
+	// Findings is the grandfathered findings per check name.
+	Findings map[string][]BaselineEntry `yaml:"findings"`
+
+	lock sync.Mutex
+	// matched is the number of findings that matched each fingerprint, per
+	// check.
+	matched map[string]map[string]int
+	// recorded is the number of findings recorded by Add() for each
+	// fingerprint, per check.
+	recorded map[string]map[string]int
+	// ran is the checks that ran; false if one of their runs failed without
+	// reporting findings, so it is unknown if their entries are still valid.
+	ran map[string]bool
+}
+
+// BaselineEntry is one grandfathered finding.
+type BaselineEntry struct {
+	Fingerprint string `yaml:"fingerprint"`
+	// Path and Message are informative, to help review changes to the file.
+	Path    string `yaml:"path"`
+	Message string `yaml:"message"`
+}
+
+// LoadBaseline loads the baseline file at the root of the repository. It
+// returns an empty baseline if there is no file.
+func LoadBaseline(root string) (*Baseline, error) {
+	b := &Baseline{Findings: map[string][]BaselineEntry{}}
+	content, err := ioutil.ReadFile(filepath.Join(root, BaselineFile))
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, b); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", BaselineFile, err)
+	}
+	if b.Findings == nil {
+		b.Findings = map[string][]BaselineEntry{}
+	}
+	return b, nil
+}
+
+// Save writes the baseline file at the root of the repository.
+func (b *Baseline) Save(root string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, entries := range b.Findings {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Path != entries[j].Path {
+				return entries[i].Path < entries[j].Path
+			}
+			if entries[i].Message != entries[j].Message {
+				return entries[i].Message < entries[j].Message
+			}
+			return entries[i].Fingerprint < entries[j].Fingerprint
+		})
+	}
+	content, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	header := "# Findings grandfathered by pcg. Generated by 'pcg baseline'; update with\n# 'pcg baseline' or remove fixed findings with 'pcg baseline prune'.\n\n"
+	return ioutil.WriteFile(filepath.Join(root, BaselineFile), append([]byte(header), content...), 0666)
+}
+
+// Len returns the number of grandfathered findings.
+func (b *Baseline) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	n := 0
+	for _, entries := range b.Findings {
+		n += len(entries)
+	}
+	return n
+}
+
+// Filter removes from err the findings of the check that are in the baseline.
+// err is the value returned by the check's Run(). It returns nil when all the
+// findings are grandfathered.
+//
+// Errors that do not contain findings, like a test failure, are returned as
+// is.
+func (b *Baseline) Filter(change scm.Change, check string, err error) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	fe, ok := b.markRan(check, err)
+	if !ok {
+		return err
+	}
+	available := map[string]int{}
+	for _, e := range b.Findings[check] {
+		available[e.Fingerprint]++
+	}
+	if b.matched[check] == nil {
+		b.matched[check] = map[string]int{}
+	}
+	// A check enabled in multiple modes runs multiple times, so count the
+	// matches per run.
+	seen := map[string]int{}
+	var kept []finding
+	for _, f := range fe.findings {
+		fp := fingerprint(change, check, &f)
+		if seen[fp] < available[fp] {
+			seen[fp]++
+			if seen[fp] > b.matched[check][fp] {
+				b.matched[check][fp] = seen[fp]
+			}
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return newFindingsError(fe.header, kept)
+}
+
+// Add records the findings in err in the baseline, replacing the entries
+// previously recorded for the check. err is the value returned by the check's
+// Run().
+//
+// It returns false if err is an error without findings, like a test failure,
+// that can't be recorded; the entries of the check are then left untouched.
+func (b *Baseline) Add(change scm.Change, check string, err error) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	fe, ok := b.markRan(check, err)
+	if !ok && err != nil {
+		return false
+	}
+	if b.recorded[check] == nil {
+		// First run of the check; drop the stale entries.
+		b.recorded[check] = map[string]int{}
+		delete(b.Findings, check)
+	}
+	if fe == nil {
+		return true
+	}
+	// A check enabled in multiple modes runs multiple times, so only record
+	// the findings not already reported by a previous run.
+	seen := map[string]int{}
+	for _, f := range fe.findings {
+		fp := fingerprint(change, check, &f)
+		seen[fp]++
+		if seen[fp] > b.recorded[check][fp] {
+			b.recorded[check][fp] = seen[fp]
+			b.Findings[check] = append(b.Findings[check], BaselineEntry{fp, f.path, f.message})
+		}
+	}
+	return true
+}
+
+// Prune removes the entries that didn't match a finding in calls to Filter()
+// and returns the number of entries removed.
+//
+// The entries of checks that didn't run or failed without reporting findings
+// are kept.
+func (b *Baseline) Prune() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	removed := 0
+	for check, entries := range b.Findings {
+		if !b.ran[check] {
+			continue
+		}
+		left := map[string]int{}
+		for fp, n := range b.matched[check] {
+			left[fp] = n
+		}
+		var kept []BaselineEntry
+		for _, e := range entries {
+			if left[e.Fingerprint] > 0 {
+				left[e.Fingerprint]--
+				kept = append(kept, e)
+			} else {
+				removed++
+			}
+		}
+		if len(kept) == 0 {
+			delete(b.Findings, check)
+		} else {
+			b.Findings[check] = kept
+		}
+	}
+	return removed
+}
+
+// Private stuff.
+
+// markRan records that check ran and returns the findings in err, if any.
+//
+// b.lock must be held.
+func (b *Baseline) markRan(check string, err error) (*findingsError, bool) {
+	if b.ran == nil {
+		b.ran = map[string]bool{}
+		b.matched = map[string]map[string]int{}
+		b.recorded = map[string]map[string]int{}
+	}
+	fe, ok := err.(*findingsError)
+	if ran, seen := b.ran[check]; !seen || ran {
+		b.ran[check] = ok || err == nil
+	}
+	return fe, ok
+}
+
+// fingerprint returns a stable identifier for a finding that doesn't depend on
+// its line number.
+//
+// It hashes the check, the file, the rule, the message and the line with the
+// finding and the lines around it, ignoring indentation.
+func fingerprint(change scm.Change, check string, f *finding) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", check, f.path, f.rule, f.message)
+	if f.line > 0 {
+		if content := change.Content(filepath.FromSlash(f.path)); content != nil {
+			lines := strings.Split(string(content), "\n")
+			for i := f.line - 2; i <= f.line; i++ {
+				if i >= 0 && i < len(lines) {
+					fmt.Fprintf(h, "%s\n", strings.TrimSpace(lines[i]))
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go": "package foo\n" +
+			"\n" +
+			"var a = 1\n" +
+			"\n" +
+			"var b = 1\n",
+	})
+	root := change.Repo().Root()
+	found := &findingsError{"go vet failed:", []finding{
+		{path: "foo.go", line: 3, rule: "shadow", message: "bad"},
+		{path: "foo.go", line: 5, rule: "shadow", message: "bad"},
+		{path: "foo.go", message: "missing copyright"},
+	}}
+
+	b, err := LoadBaseline(root)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, 0, b.Len())
+	ut.AssertEqual(t, true, b.Add(change, "govet", found))
+	ut.AssertEqual(t, true, b.Add(change, "govet", found))
+	ut.AssertEqual(t, true, b.Add(change, "gofmt", nil))
+	ut.AssertEqual(t, false, b.Add(change, "gotest", errors.New("test failed")))
+	ut.AssertEqual(t, 3, b.Len())
+	ut.AssertEqual(t, nil, b.Save(root))
+
+	// Lines are not part of the fingerprint, only the surrounding code.
+	moved := "package foo\n" +
+		"\n" +
+		"// New comment.\n" +
+		"\n" +
+		"var a = 1\n" +
+		"\n" +
+		"var b = 1\n"
+	ut.AssertEqual(t, nil, ioutil.WriteFile(filepath.Join(root, "foo.go"), []byte(moved), 0600))
+	b, err = LoadBaseline(root)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, 3, b.Len())
+	now := &findingsError{"go vet failed:", []finding{
+		{path: "foo.go", line: 5, rule: "shadow", message: "bad"},
+		{path: "foo.go", line: 7, rule: "shadow", message: "new"},
+	}}
+	expected := &findingsError{"go vet failed:", []finding{
+		{path: "foo.go", line: 7, rule: "shadow", message: "new"},
+	}}
+	ut.AssertEqual(t, expected, b.Filter(change, "govet", now))
+	ut.AssertEqual(t, nil, b.Filter(change, "govet", &findingsError{"go vet failed:", now.findings[:1]}))
+	failure := errors.New("test failed")
+	ut.AssertEqual(t, failure, b.Filter(change, "gotest", failure))
+
+	ut.AssertEqual(t, 2, b.Prune())
+	ut.AssertEqual(t, 1, b.Len())
+	ut.AssertEqual(t, "foo.go", b.Findings["govet"][0].Path)
+}
@@ -0,0 +1,473 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Bench runs benchmarks on both the base commit and the current tree and
+// fails when a benchmark regressed.
+//
+// The comparison is done like benchstat; outliers are removed and a
+// Mann-Whitney U-test is used to determine if the difference is significant.
+type Bench struct {
+	// Bench is the regexp passed to -bench. Defaults to "." when empty.
+	Bench string `yaml:"bench"`
+	// Count is the number of times each benchmark is run on each tree. Defaults
+	// to 5 when zero.
+	Count int `yaml:"count"`
+	// MaxRegression is the maximum allowed regression in percent for a
+	// statistically significant difference.
+	MaxRegression float64 `yaml:"max_regression"`
+	// ExtraArgs are additional arguments to pass to go test, e.g. -benchmem.
+	ExtraArgs []string `yaml:"extra_args"`
+}
+
+// GetDescription implements Check.
+func (b *Bench) GetDescription() string {
+	return "enforces benchmarks do not regress compared to the base commit"
+}
+
+// GetName implements Check.
+func (b *Bench) GetName() string {
+	return "bench"
+}
+
+// GetPrerequisites implements Check.
+func (b *Bench) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (b *Bench) Run(change scm.Change, options *Options) (err error) {
+	base := change.Base()
+	if base == scm.Initial || base == scm.Invalid || base == "" {
+		log.Printf("bench: no base commit to compare against")
+		return nil
+	}
+	testPkgs := change.Indirect().TestPackages()
+	if len(testPkgs) == 0 {
+		return nil
+	}
+
+	tmpDir, err2 := ioutil.TempDir("", "pre-commit-go")
+	if err2 != nil {
+		return err2
+	}
+	defer func() {
+		err2 := internal.RemoveAll(tmpDir)
+		if err == nil {
+			err = err2
+		}
+	}()
+	oldRoot, oldGOPATH, err := exportTree(change, base, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	// Each run of go test does a single iteration of the benchmarks, alternating
+	// the new and the old tree, so a transient slowdown of the machine affects
+	// both trees alike. The other checks of the mode still run concurrently; use
+	// a mode with only bench or max_concurrent: 1 for more stable results.
+	var deltas []*benchDelta
+	for _, testPkg := range testPkgs {
+		args := b.args(options, testPkg)
+		var newOut, oldOut string
+		for i := 0; i < b.count(); i++ {
+			out, exitCode, _, _ := options.Capture(change.Repo(), args...)
+			if exitCode != 0 {
+				return fmt.Errorf("%s failed:\n%s", strings.Join(args, " "), processStackTrace(out))
+			}
+			if i == 0 && len(parseBenchmarks(out)) == 0 {
+				break
+			}
+			newOut += out
+			if out, exitCode, _, _ = options.captureIn(oldRoot, oldGOPATH, args...); exitCode != 0 {
+				// The package may not exist or not build at the base commit.
+				log.Printf("bench: %s failed on %s; skipping:\n%s", testPkg, base, out)
+				oldOut = ""
+				break
+			}
+			oldOut += out
+		}
+		if oldOut == "" {
+			continue
+		}
+		deltas = append(deltas, compareBenchmarks(testPkg, parseBenchmarks(oldOut), parseBenchmarks(newOut))...)
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	table := formatBenchDeltas(deltas)
+	for _, d := range deltas {
+		if d.isRegression(b.MaxRegression) {
+			return fmt.Errorf("benchmarks regressed by more than %.1f%% compared to %s:\n%s", b.MaxRegression, base, table)
+		}
+	}
+	log.Printf("benchmarks compared to %s:\n%s", base, table)
+	return nil
+}
+
+// args returns the command running a single iteration of the benchmarks of
+// testPkg.
+func (b *Bench) args(options *Options, testPkg string) []string {
+	bench := b.Bench
+	if bench == "" {
+		bench = "."
+	}
+	args := []string{
+		"go", "test", "-run=^$", "-bench", bench, "-count", "1",
+		"-timeout", fmt.Sprintf("%ds", options.MaxDuration),
+	}
+	args = append(args, b.ExtraArgs...)
+	return append(args, testPkg)
+}
+
+// count returns the number of runs on each tree.
+func (b *Bench) count() int {
+	if b.Count <= 0 {
+		return 5
+	}
+	return b.Count
+}
+
+// validate implements validator.
+func (b *Bench) validate() []*ConfigError {
+	var out []*ConfigError
+	if b.Count < 0 {
+		out = append(out, &ConfigError{Path: "count", Message: "must not be negative"})
+	}
+	if b.MaxRegression < 0 {
+		out = append(out, &ConfigError{Path: "max_regression", Message: "must not be negative"})
+	}
+	return out
+}
+
+// Private stuff.
+
+// benchAlpha is the significance level, the same default as benchstat.
+const benchAlpha = 0.05
+
+// benchResults is the parsed output of go test -bench; benchmark name : unit :
+// samples.
+type benchResults map[string]map[string][]float64
+
+// parseBenchmarks parses the output of go test -bench.
+//
+// A line looks like "BenchmarkFoo-8 1000000 1234 ns/op 16 B/op 1 allocs/op"
+// with tabs as separators.
+func parseBenchmarks(out string) benchResults {
+	results := benchResults{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			if results[fields[0]] == nil {
+				results[fields[0]] = map[string][]float64{}
+			}
+			results[fields[0]][fields[i+1]] = append(results[fields[0]][fields[i+1]], v)
+		}
+	}
+	return results
+}
+
+// benchDelta is the comparison of one metric of one benchmark.
+type benchDelta struct {
+	pkg    string
+	name   string
+	unit   string
+	old    benchMetric
+	new    benchMetric
+	pValue float64
+}
+
+// change returns the relative change in percent, positive being worse.
+func (d *benchDelta) change() float64 {
+	if d.old.mean == 0 {
+		return 0
+	}
+	c := 100. * (d.new.mean - d.old.mean) / d.old.mean
+	if strings.HasSuffix(d.unit, "/s") {
+		// Throughput; higher is better.
+		c = -c
+	}
+	return c
+}
+
+func (d *benchDelta) isSignificant() bool {
+	return d.pValue < benchAlpha
+}
+
+func (d *benchDelta) isRegression(maxRegression float64) bool {
+	return d.isSignificant() && d.change() > maxRegression
+}
+
+func (d *benchDelta) String() string {
+	if !d.isSignificant() {
+		return fmt.Sprintf("~ (p=%.3f n=%d+%d)", d.pValue, len(d.old.values), len(d.new.values))
+	}
+	return fmt.Sprintf("%+.2f%% (p=%.3f n=%d+%d)", d.change(), d.pValue, len(d.old.values), len(d.new.values))
+}
+
+// benchMetric is the samples of one metric with outliers removed.
+type benchMetric struct {
+	values []float64
+	mean   float64
+	// diff is the largest deviation from the mean, in percent.
+	diff float64
+}
+
+func newBenchMetric(values []float64) benchMetric {
+	m := benchMetric{values: removeOutliers(values)}
+	for _, v := range m.values {
+		m.mean += v
+	}
+	m.mean /= float64(len(m.values))
+	if m.mean != 0 {
+		for _, v := range m.values {
+			if d := 100. * math.Abs(v-m.mean) / m.mean; d > m.diff {
+				m.diff = d
+			}
+		}
+	}
+	return m
+}
+
+func (m *benchMetric) String() string {
+	return fmt.Sprintf("%s ±%2.0f%%", formatBenchValue(m.mean), m.diff)
+}
+
+// compareBenchmarks returns the deltas of all benchmarks present in both old
+// and new.
+func compareBenchmarks(pkg string, oldResults, newResults benchResults) []*benchDelta {
+	var out []*benchDelta
+	for name, units := range newResults {
+		for unit, newValues := range units {
+			oldValues := oldResults[name][unit]
+			if len(oldValues) == 0 {
+				continue
+			}
+			d := &benchDelta{
+				pkg:  pkg,
+				name: name,
+				unit: unit,
+				old:  newBenchMetric(oldValues),
+				new:  newBenchMetric(newValues),
+			}
+			d.pValue = mannWhitneyU(d.old.values, d.new.values)
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].name != out[j].name {
+			return out[i].name < out[j].name
+		}
+		return out[i].unit < out[j].unit
+	})
+	return out
+}
+
+// formatBenchDeltas returns a table similar to benchstat's.
+func formatBenchDeltas(deltas []*benchDelta) string {
+	rows := [][]string{{"name", "old", "new", "delta"}}
+	for _, d := range deltas {
+		rows = append(rows, []string{
+			d.pkg + " " + d.name,
+			d.old.String() + " " + d.unit,
+			d.new.String() + " " + d.unit,
+			d.String(),
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if l := utf8.RuneCountInString(cell); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+	out := ""
+	for _, row := range rows {
+		line := ""
+		for i, cell := range row {
+			if i == len(row)-1 {
+				line += cell
+			} else {
+				line += cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+		}
+		out += strings.TrimRight(line, " ") + "\n"
+	}
+	return out
+}
+
+// formatBenchValue formats a value with 3 significant digits and a metric
+// suffix.
+func formatBenchValue(v float64) string {
+	for _, s := range []struct {
+		scale  float64
+		suffix string
+	}{{1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if math.Abs(v) >= s.scale {
+			return strconv.FormatFloat(v/s.scale, 'g', 3, 64) + s.suffix
+		}
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// removeOutliers removes values outside of the Tukey fences (1.5 times the
+// interquartile range).
+func removeOutliers(values []float64) []float64 {
+	s := make([]float64, len(values))
+	copy(s, values)
+	sort.Float64s(s)
+	if len(s) < 4 {
+		return s
+	}
+	q1 := quantile(s, 0.25)
+	q3 := quantile(s, 0.75)
+	lo := q1 - 1.5*(q3-q1)
+	hi := q3 + 1.5*(q3-q1)
+	out := make([]float64, 0, len(s))
+	for _, v := range s {
+		if v >= lo && v <= hi {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// quantile returns the q quantile of sorted values with linear interpolation.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// benchExactMax is the largest sample size for which the exact distribution
+// of the Mann-Whitney U statistic is used, like benchstat.
+const benchExactMax = 50
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U-test.
+//
+// Like benchstat, it uses the exact distribution of U for small samples.
+// Unlike benchstat, it falls back to the normal approximation with tie and
+// continuity corrections when there are ties, as benchstat computes the exact
+// distribution with ties too.
+func mannWhitneyU(x, y []float64) float64 {
+	n1 := float64(len(x))
+	n2 := float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type sample struct {
+		v     float64
+		fromX bool
+	}
+	all := make([]sample, 0, len(x)+len(y))
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Assign average ranks to ties.
+	r1 := 0.
+	tieSum := 0.
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				r1 += rank
+			}
+		}
+		t := float64(j - i)
+		tieSum += t*t*t - t
+		i = j
+	}
+	u := r1 - n1*(n1+1)/2
+	if tieSum == 0 && len(x) <= benchExactMax && len(y) <= benchExactMax {
+		return mannWhitneyExact(len(x), len(y), int(u))
+	}
+	mean := n1 * n2 / 2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - tieSum/(n*(n-1)))
+	if variance <= 0 {
+		// All values are equal.
+		return 1
+	}
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// mannWhitneyExact returns the two-sided p-value of the statistic u for
+// samples of sizes n1 and n2 without ties, using the exact distribution of U.
+func mannWhitneyExact(n1, n2, u int) float64 {
+	// counts[i][v] is the number of orderings of i values of x and j values of
+	// y where U is v, computed for j from 0 to n2 with the recurrence
+	// N(i, j, v) = N(i-1, j, v-j) + N(i, j-1, v).
+	counts := make([][]float64, n1+1)
+	for i := range counts {
+		counts[i] = make([]float64, n1*n2+1)
+	}
+	for i := range counts {
+		counts[i][0] = 1
+	}
+	for j := 1; j <= n2; j++ {
+		for i := 1; i <= n1; i++ {
+			for v := len(counts[i]) - 1; v >= j; v-- {
+				counts[i][v] += counts[i-1][v-j]
+			}
+		}
+	}
+	total, below := 0., 0.
+	for v, c := range counts[n1] {
+		total += c
+		if v <= u {
+			below += c
+		}
+	}
+	// U is symmetric around n1*n2/2; count the tail on the side of u.
+	if u > n1*n2-u {
+		u = n1*n2 - u
+		below = 0
+		for v := 0; v <= u; v++ {
+			below += counts[n1][v]
+		}
+	}
+	return math.Min(1, 2*below/total)
+}
@@ -0,0 +1,157 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+	"github.com/maruel/ut"
+)
+
+func TestBenchRun(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	setup(t, td, map[string]string{
+		"foo.go":      benchWork(1),
+		"foo_test.go": "package foo\n\nimport \"testing\"\n\nfunc BenchmarkWork(b *testing.B) {\n\tfor i := 0; i < b.N; i++ {\n\t\tWork()\n\t}\n}\n",
+	})
+	fooDir := filepath.Join(td, "src", "foo")
+	out, code, err := internal.Capture(fooDir, nil, "git", "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "-q", "-m", "base")
+	ut.AssertEqualf(t, 0, code, out)
+	ut.AssertEqual(t, nil, err)
+	// Make Work() 100 times slower.
+	ut.AssertEqual(t, nil, ioutil.WriteFile(filepath.Join(fooDir, "foo.go"), []byte(benchWork(100)), 0600))
+	repo, err := scm.GetRepo(fooDir, td)
+	ut.AssertEqual(t, nil, err)
+	change, err := repo.Between(scm.Current, repo.Eval("HEAD"), nil)
+	ut.AssertEqual(t, nil, err)
+
+	// Enough runs for the difference to stay significant once the outliers of
+	// a busy machine are removed.
+	b := &Bench{Count: 10, ExtraArgs: []string{"-benchtime", "100x"}}
+	err = b.Run(change, &Options{MaxDuration: 60})
+	ut.AssertEqual(t, true, err != nil)
+	ut.AssertEqual(t, true, strings.HasPrefix(err.Error(), "benchmarks regressed by more than 0.0%"))
+	b.MaxRegression = 1000000
+	ut.AssertEqual(t, nil, b.Run(change, &Options{MaxDuration: 60}))
+}
+
+func TestParseBenchmarks(t *testing.T) {
+	t.Parallel()
+	out := "goos: linux\n" +
+		"BenchmarkFoo-8   \t 1000000\t      1234 ns/op\t  16 B/op\t 1 allocs/op\n" +
+		"BenchmarkFoo-8   \t 1000000\t      1236 ns/op\t  16 B/op\t 1 allocs/op\n" +
+		"BenchmarkBar-8   \t 200\t      4.5 ns/op\n" +
+		"BenchmarkBroken  \t foo\n" +
+		"PASS\n"
+	expected := benchResults{
+		"BenchmarkFoo-8": {
+			"ns/op":     {1234, 1236},
+			"B/op":      {16, 16},
+			"allocs/op": {1, 1},
+		},
+		"BenchmarkBar-8": {"ns/op": {4.5}},
+	}
+	ut.AssertEqual(t, expected, parseBenchmarks(out))
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	t.Parallel()
+	oldResults := benchResults{
+		"BenchmarkFast": {"ns/op": {100, 102, 98, 101, 99}},
+		"BenchmarkSame": {"ns/op": {100, 102, 98, 101, 99}},
+		"BenchmarkGone": {"ns/op": {100}},
+	}
+	newResults := benchResults{
+		"BenchmarkFast": {"ns/op": {150, 153, 147, 151, 149}},
+		"BenchmarkSame": {"ns/op": {99, 101, 100, 98, 102}},
+		"BenchmarkNew":  {"ns/op": {100}},
+	}
+	deltas := compareBenchmarks("./foo", oldResults, newResults)
+	ut.AssertEqual(t, 2, len(deltas))
+	ut.AssertEqual(t, "BenchmarkFast", deltas[0].name)
+	ut.AssertEqual(t, true, deltas[0].isSignificant())
+	ut.AssertEqual(t, 50., deltas[0].change())
+	ut.AssertEqual(t, true, deltas[0].isRegression(10))
+	ut.AssertEqual(t, false, deltas[0].isRegression(60))
+	ut.AssertEqual(t, "BenchmarkSame", deltas[1].name)
+	ut.AssertEqual(t, false, deltas[1].isSignificant())
+	ut.AssertEqual(t, false, deltas[1].isRegression(0))
+
+	expected := "name                 old             new             delta\n" +
+		"./foo BenchmarkFast  100 ± 2% ns/op  150 ± 2% ns/op  +50.00% (p=0.008 n=5+5)\n" +
+		"./foo BenchmarkSame  100 ± 2% ns/op  100 ± 2% ns/op  ~ (p=1.000 n=5+5)\n"
+	ut.AssertEqual(t, expected, formatBenchDeltas(deltas))
+}
+
+func TestBenchThroughput(t *testing.T) {
+	t.Parallel()
+	d := &benchDelta{
+		unit: "MB/s",
+		old:  newBenchMetric([]float64{100, 100, 100}),
+		new:  newBenchMetric([]float64{50, 50, 50}),
+	}
+	ut.AssertEqual(t, 50., d.change())
+}
+
+func TestRemoveOutliers(t *testing.T) {
+	t.Parallel()
+	ut.AssertEqual(t, []float64{1, 2}, removeOutliers([]float64{2, 1}))
+	ut.AssertEqual(t, []float64{10, 10, 11, 11, 12}, removeOutliers([]float64{10, 11, 12, 10, 11, 1000}))
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	t.Parallel()
+	ut.AssertEqual(t, 1., mannWhitneyU([]float64{1, 1, 1}, []float64{1, 1, 1}))
+	ut.AssertEqual(t, 1., mannWhitneyU(nil, []float64{1}))
+	// Exact distribution, as benchstat: 2 of the 252 orderings are as extreme.
+	ut.AssertEqual(t, 2./252, mannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}))
+	ut.AssertEqual(t, 2./252, mannWhitneyU([]float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}))
+	ut.AssertEqual(t, 1., mannWhitneyU([]float64{1, 4, 5, 8, 9}, []float64{2, 3, 6, 7, 10}))
+	ut.AssertEqual(t, 2./15, mannWhitneyU([]float64{1, 2}, []float64{3, 4, 5, 6}))
+	// Ties use the normal approximation.
+	p := mannWhitneyU([]float64{1, 2, 3, 4, 5, 5}, []float64{5, 7, 8, 9, 10, 11})
+	if p > 0.05 || p < 0.001 {
+		t.Fatalf("expected significant difference, got p=%f", p)
+	}
+}
+
+func TestMannWhitneyExact(t *testing.T) {
+	t.Parallel()
+	// For n1=3, n2=4, the one-sided P(U<=1) is 2/35 so the two-sided p-value
+	// is 4/35, the same for U=11 by symmetry. U=6 is the mean so p is 1.
+	ut.AssertEqual(t, 4./35, mannWhitneyExact(3, 4, 1))
+	ut.AssertEqual(t, 4./35, mannWhitneyExact(3, 4, 11))
+	ut.AssertEqual(t, 1., mannWhitneyExact(3, 4, 6))
+}
+
+func TestFormatBenchValue(t *testing.T) {
+	t.Parallel()
+	ut.AssertEqual(t, "1.23k", formatBenchValue(1234))
+	ut.AssertEqual(t, "4.5", formatBenchValue(4.5))
+	ut.AssertEqual(t, "2M", formatBenchValue(2000000))
+}
+
+// Private stuff.
+
+// benchWork returns a package with a function Work() doing n units of work.
+func benchWork(n int) string {
+	return "package foo\n\nfunc Work() int {\n\ts := 0\n\tfor i := 0; i < " + strconv.Itoa(n) + "*10000; i++ {\n\t\ts += i % 7\n\t}\n\treturn s\n}\n"
+}
@@ -9,11 +9,12 @@
 package checks
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -89,49 +90,6 @@ func (b *Build) Run(change scm.Change, options *Options) error {
 	return nil
 }
 
-// Copyright looks for copyright headers in all files.
-type Copyright struct {
-	Header string
-}
-
-// GetDescription implements Check.
-func (c *Copyright) GetDescription() string {
-	return "enforces all .go sources have copyright"
-}
-
-// GetName implements Check.
-func (c *Copyright) GetName() string {
-	return "copyright"
-}
-
-// GetPrerequisites implements Check.
-func (c *Copyright) GetPrerequisites() []CheckPrerequisite {
-	return nil
-}
-
-// Run implements Check.
-func (c *Copyright) Run(change scm.Change, options *Options) error {
-	var badFiles []string
-	prefix := []byte(c.Header)
-	// This this serially since it's I/O bound and will compete with process
-	// startup of other checks.
-	for _, f := range change.Changed().GoFiles() {
-		if !change.IsIgnored(f) {
-			if content := change.Content(f); content != nil {
-				if !bytes.HasPrefix(content, prefix) {
-					badFiles = append(badFiles, f)
-				}
-			} else {
-				badFiles = append(badFiles, f)
-			}
-		}
-	}
-	if len(badFiles) != 0 {
-		return fmt.Errorf("files have invalid copyright header:\n  %s", strings.Join(badFiles, "\n  "))
-	}
-	return nil
-}
-
 // Gofmt runs gofmt in check mode with code simplification enabled.
 type Gofmt struct {
 }
@@ -159,15 +117,16 @@ func (g *Gofmt) Run(change scm.Change, options *Options) error {
 	// TODO(maruel): Do it in process. It'll be much faster as the content of the
 	// modified files is already in memory.
 	out, _, _, err := options.Capture(change.Repo(), "gofmt", "-l", "-s", ".")
-	// Split the files to ignore as needed.
-	files := []string{}
+	// Split the files to ignore as needed. Generated files are skipped since
+	// they can't be fixed by hand.
+	var files []finding
 	for _, line := range strings.Split(string(out), "\n") {
-		if len(line) != 0 && !change.IsIgnored(line) {
-			files = append(files, line)
+		if len(line) != 0 && !change.IsIgnored(line) && !change.IsGenerated(line) {
+			files = append(files, finding{path: line})
 		}
 	}
 	if len(files) != 0 {
-		return fmt.Errorf("these files are improperly formatted, please run: gofmt -w -s .\n%s", strings.Join(files, "\n"))
+		return newFindingsError("these files are improperly formatted, please run: gofmt -w -s .", files)
 	}
 	if err != nil {
 		return fmt.Errorf("gofmt -l -s . failed: %s", err)
@@ -175,6 +134,15 @@ func (g *Gofmt) Run(change scm.Change, options *Options) error {
 	return nil
 }
 
+// fix implements fixer.
+func (g *Gofmt) fix(change scm.Change, options *Options, dir string, files []string) error {
+	args := append([]string{"gofmt", "-s", "-w"}, files...)
+	if out, _, _, err := options.captureIn(dir, "", args...); err != nil || len(out) != 0 {
+		return fmt.Errorf("gofmt -s -w failed: %s\n%s", err, out)
+	}
+	return nil
+}
+
 // Test runs all tests via go test.
 type Test struct {
 	ExtraArgs []string `yaml:"extra_args"`
@@ -202,7 +170,12 @@ func (t *Test) Run(change scm.Change, options *Options) error {
 	// With go 1.4, 'go test' now correctly build all packages even if they have
 	// no test. https://golang.org/doc/go1.4#gocmd
 	testPkgs := change.Indirect().Packages()
-	errs := make(chan error, len(testPkgs))
+	type failure struct {
+		testPkg string
+		args    []string
+		out     string
+	}
+	failures := make(chan *failure, len(testPkgs))
 	for _, tp := range testPkgs {
 		wg.Add(1)
 		go func(testPkg string) {
@@ -219,17 +192,33 @@ func (t *Test) Run(change scm.Change, options *Options) error {
 				log.Printf("%s was slow: %s", args, round(duration, time.Millisecond))
 			}
 			if exitCode != 0 {
-				errs <- fmt.Errorf("%s failed:\n%s", strings.Join(args, " "), processStackTrace(out))
+				failures <- &failure{testPkg, args, out}
 			}
 		}(tp)
 	}
 	wg.Wait()
-	select {
-	case err := <-errs:
-		return err
-	default:
+	close(failures)
+
+	// Race reports are processed separately so the same race found in multiple
+	// packages is reported once.
+	var errs []string
+	var races []*raceReport
+	for f := range failures {
+		reports, rest := parseRaces(change, f.out)
+		for _, r := range reports {
+			r.pkgs = []string{f.testPkg}
+		}
+		races = append(races, reports...)
+		errs = append(errs, fmt.Sprintf("%s failed:\n%s", strings.Join(f.args, " "), processTestOutput(change, rest)))
 	}
-	return nil
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	if len(races) != 0 {
+		errs = append(errs, formatRaces(change, races))
+	}
+	return errors.New(strings.Join(errs, "\n"))
 }
 
 // Errcheck runs errcheck on packages.
@@ -260,10 +249,9 @@ func (e *Errcheck) Run(change scm.Change, options *Options) error {
 	args := []string{"errcheck", "-ignore", e.Ignores}
 	out, _, _, err := options.Capture(change.Repo(), append(args, change.Changed().Packages()...)...)
 	if len(out) != 0 {
-		// TODO(maruel): Process output so paths are relative from
-		// change.Repo().Root().
-		// TODO(maruel): Filter out files in change.IsIgnored() and not in
-		// change.Changed().GoFiles()
+		if findings := parseFindings(change, out, ""); len(findings) != 0 {
+			return newFindingsError(strings.Join(args, " ")+" failed:", changedFindings(change, findings))
+		}
 		return fmt.Errorf("%s failed:\n%s", strings.Join(args, " "), out)
 	}
 	if err != nil {
@@ -297,9 +285,24 @@ func (g *Goimports) GetPrerequisites() []CheckPrerequisite {
 func (g *Goimports) Run(change scm.Change, options *Options) error {
 	// goimports accepts files, not packages.
 	// goimports doesn't return non-zero even if some files need to be updated.
-	out, _, _, err := options.Capture(change.Repo(), append([]string{"goimports", "-l"}, change.Changed().GoFiles()...)...)
+	// Generated files are skipped since they can't be fixed by hand.
+	args := []string{"goimports", "-l"}
+	for _, f := range change.Changed().GoFiles() {
+		if !change.IsGenerated(f) {
+			args = append(args, f)
+		}
+	}
+	if len(args) == 2 {
+		// goimports would read stdin.
+		return nil
+	}
+	out, _, _, err := options.Capture(change.Repo(), args...)
 	if len(out) != 0 {
-		return fmt.Errorf("these files are improperly formatted, please run: goimports -w <files>\n%s", out)
+		var files []finding
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			files = append(files, finding{path: line})
+		}
+		return newFindingsError("these files are improperly formatted, please run: goimports -w <files>", files)
 	}
 	if err != nil {
 		return fmt.Errorf("goimports -w . failed: %s", err)
@@ -307,6 +310,30 @@ func (g *Goimports) Run(change scm.Change, options *Options) error {
 	return nil
 }
 
+// fix implements fixer.
+//
+// The copies in dir are passed on stdin and goimports runs from the
+// repository with -srcdir, so the imports are resolved against the real tree,
+// its GOPATH and its vendored packages like Run does.
+func (g *Goimports) fix(change scm.Change, options *Options, dir string, files []string) error {
+	for _, f := range files {
+		p := filepath.Join(dir, f)
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		args := []string{"goimports", "-srcdir", filepath.Join(change.Repo().Root(), f)}
+		stdout, stderr, exitCode, err := options.captureWithInput(change.Repo(), content, args...)
+		if err != nil || exitCode != 0 {
+			return fmt.Errorf("%s failed: %v\n%s", strings.Join(args, " "), err, stderr)
+		}
+		if err := ioutil.WriteFile(p, []byte(stdout), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Golint runs golint.
 type Golint struct {
 	Blacklist []string
@@ -336,174 +363,73 @@ func (g *Golint) Run(change scm.Change, options *Options) error {
 	// - doesn't like multiple packages per call.
 	// - "." is not recursive.
 	pkgs := change.Changed().Packages()
-	resultsC := make(chan []string, len(pkgs))
-	files := map[string]bool{}
-	for _, f := range change.Changed().GoFiles() {
-		files[f] = true
-	}
+	resultsC := make(chan []finding, len(pkgs))
 	for _, pkg := range pkgs {
 		go func(p string) {
-			r := []string{}
+			var r []finding
 			out, _, _, _ := options.Capture(change.Repo(), "golint", p)
-			for _, line := range strings.Split(string(out), "\n") {
-				if len(line) == 0 {
-					continue
-				}
-				// TODO(maruel): Will fail with files with ':' in their name.
-				items := strings.SplitN(line, ":", 2)
-				if change.IsIgnored(items[0]) {
-					continue
-				}
-				if _, ok := files[items[0]]; !ok {
-					continue
-				}
+			for _, f := range parseFindings(change, out, "") {
 				for _, b := range g.Blacklist {
-					if strings.Contains(line, b) {
+					if strings.Contains(f.String(), b) {
 						goto skip
 					}
 				}
-				r = append(r, line)
+				r = append(r, f)
 			skip:
 			}
 			resultsC <- r
 		}(pkg)
 	}
 
-	results := []string{}
+	var results []finding
 	for i := 0; i < len(pkgs); i++ {
 		results = append(results, <-resultsC...)
 	}
-	if len(results) != 0 {
-		sort.Strings(results)
-		return errors.New("golint failed:\n" + strings.Join(results, "\n"))
-	}
-	return nil
-}
-
-// Govet runs "go tool vet".
-type Govet struct {
-	Blacklist []string
-}
-
-// GetDescription implements Check.
-func (g *Govet) GetDescription() string {
-	return "enforces all .go sources passes go tool vet"
-}
-
-// GetName implements Check.
-func (g *Govet) GetName() string {
-	return "govet"
-}
-
-// GetPrerequisites implements Check.
-func (g *Govet) GetPrerequisites() []CheckPrerequisite {
-	return []CheckPrerequisite{
-		{[]string{"go", "tool", "vet", "-h"}, 1, "golang.org/x/tools/cmd/vet"},
-	}
-}
-
-// Run implements Check.
-func (g *Govet) Run(change scm.Change, options *Options) error {
-	// - accepts packages, not files.
-	// - returns non-zero on report.
-	// - accepts multiple packages per call.
-	// - "." is recursive.
-	// Ignore the return code since we ignore many errors.
-	out, _, _, _ := options.Capture(change.Repo(), "go", "tool", "vet", "-all", ".")
-	result := []string{}
-	files := map[string]bool{}
-	for _, f := range change.Changed().GoFiles() {
-		files[f] = true
-	}
-	for _, line := range strings.Split(string(out), "\n") {
-		if len(line) == 0 {
-			continue
-		}
-		// TODO(maruel): Will fail with files with ':' in their name.
-		items := strings.SplitN(line, ":", 2)
-		if change.IsIgnored(items[0]) {
-			continue
-		}
-		if _, ok := files[items[0]]; !ok {
-			continue
-		}
-		for _, b := range g.Blacklist {
-			if strings.Contains(line, b) {
-				goto skip
-			}
-		}
-		result = append(result, line)
-	skip:
-	}
-	if len(result) != 0 {
-		return errors.New("go tool vet failed:\n" + strings.Join(result, "\n"))
-	}
-	return nil
-}
-
-// Extensibility.
-
-// Custom represents a user configured check running an external program.
-//
-// It can be used multiple times to run multiple external checks.
-type Custom struct {
-	// DisplayName is check's display name, required.
-	DisplayName string `yaml:"display_name"`
-	// Description is check's description, optional.
-	Description string `yaml:"description"`
-	// Command is check's command line, required.
-	Command []string `yaml:"command"`
-	// CheckExitCode specifies if the check is declared to fail when exit code is
-	// non-zero.
-	CheckExitCode bool `yaml:"check_exit_code"`
-	// Prerequisites are check's prerequisite packages to install first before
-	// running the check, optional.
-	Prerequisites []CheckPrerequisite `yaml:"prerequisites"`
-}
-
-// GetDescription implements Check.
-func (c *Custom) GetDescription() string {
-	if c.Description != "" {
-		return c.Description
-	}
-	return "runs a custom check from an external package"
-}
-
-// GetName implements Check.
-func (c *Custom) GetName() string {
-	return "custom"
-}
-
-// GetPrerequisites implements Check.
-func (c *Custom) GetPrerequisites() []CheckPrerequisite {
-	return c.Prerequisites
-}
-
-// Run implements Check.
-func (c *Custom) Run(change scm.Change, options *Options) error {
-	// TODO(maruel): Make what is passed to the command configurable, e.g. one of:
-	// (Changed, Indirect, All) x (GoFiles, Packages, TestPackages)
-	out, exitCode, _, err := options.Capture(change.Repo(), c.Command...)
-	if exitCode != 0 && c.CheckExitCode {
-		return fmt.Errorf("\"%s\" failed with code %d:\n%s", strings.Join(c.Command, " "), exitCode, out)
-	}
-	return err
+	return newFindingsError("golint failed:", changedFindings(change, results))
 }
 
 // Rest.
 
 // KnownChecks is the map of all known checks per check name.
+//
+// Use Register() to add a check.
 var KnownChecks = map[string]func() Check{
-	(&Build{}).GetName():     func() Check { return &Build{} },
-	(&Copyright{}).GetName(): func() Check { return &Copyright{} },
-	(&Coverage{}).GetName():  func() Check { return &Coverage{} },
-	(&Custom{}).GetName():    func() Check { return &Custom{} },
-	(&Errcheck{}).GetName():  func() Check { return &Errcheck{} },
-	(&Gofmt{}).GetName():     func() Check { return &Gofmt{} },
-	(&Goimports{}).GetName(): func() Check { return &Goimports{} },
-	(&Golint{}).GetName():    func() Check { return &Golint{} },
-	(&Govet{}).GetName():     func() Check { return &Govet{} },
-	(&Test{}).GetName():      func() Check { return &Test{} },
+	(&Analyzers{}).GetName():    func() Check { return &Analyzers{} },
+	(&Bench{}).GetName():        func() Check { return &Bench{} },
+	(&Build{}).GetName():        func() Check { return &Build{} },
+	(&Copyright{}).GetName():    func() Check { return &Copyright{} },
+	(&Coverage{}).GetName():     func() Check { return &Coverage{} },
+	(&Custom{}).GetName():       func() Check { return &Custom{} },
+	(&Errcheck{}).GetName():     func() Check { return &Errcheck{} },
+	(&Fuzz{}).GetName():         func() Check { return &Fuzz{} },
+	(&Generate{}).GetName():     func() Check { return &Generate{} },
+	(&Gofmt{}).GetName():        func() Check { return &Gofmt{} },
+	(&Goimports{}).GetName():    func() Check { return &Goimports{} },
+	(&GolangciLint{}).GetName(): func() Check { return &GolangciLint{} },
+	(&Golint{}).GetName():       func() Check { return &Golint{} },
+	(&Govet{}).GetName():        func() Check { return &Govet{} },
+	(&Modules{}).GetName():      func() Check { return &Modules{} },
+	(&Staticcheck{}).GetName():  func() Check { return &Staticcheck{} },
+	(&Test{}).GetName():         func() Check { return &Test{} },
+}
+
+// Register adds a check to KnownChecks so it can be used in the configuration
+// file with the check type name.
+//
+// It is meant to be called from an init() function of a binary embedding pcg,
+// see package runner. It panics if the name is already registered or doesn't
+// match the name returned by the check's GetName().
+func Register(name string, factory func() Check) {
+	if factory == nil {
+		panic("checks: Register factory is nil")
+	}
+	if _, ok := KnownChecks[name]; ok {
+		panic(fmt.Sprintf("checks: Register called twice for check %q", name))
+	}
+	if actual := factory().GetName(); actual != name {
+		panic(fmt.Sprintf("checks: Register called with %q for check %q", name, actual))
+	}
+	KnownChecks[name] = factory
 }
 
 // Private stuff.
@@ -7,6 +7,7 @@ package checks
 import (
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"testing"
@@ -15,6 +16,7 @@ import (
 	"github.com/maruel/pre-commit-go/internal"
 	"github.com/maruel/pre-commit-go/scm"
 	"github.com/maruel/ut"
+	"gopkg.in/yaml.v2"
 )
 
 func TestCheckPrerequisite(t *testing.T) {
@@ -41,6 +43,8 @@ func TestChecksSuccess(t *testing.T) {
 	for _, name := range getKnownChecks() {
 		c := KnownChecks[name]()
 		switch name {
+		case "analyzers":
+			c = &Analyzers{Analyzers: []string{"shadow"}}
 		case "custom":
 			c = &Custom{
 				Description:   "foo",
@@ -87,9 +91,17 @@ func TestChecksFailure(t *testing.T) {
 	for _, name := range getKnownChecks() {
 		c := KnownChecks[name]()
 		switch name {
+		case "analyzers":
+			c = &Analyzers{Analyzers: []string{"shadow"}}
+		case "bench":
+			// There is no base commit to compare against.
+			continue
 		case "build":
 			// This check is obsolete.
 			continue
+		case "modules":
+			// There is no go.mod in the tree, see TestModules.
+			continue
 		case "custom":
 			c = &Custom{
 				Description:   "foo",
@@ -128,26 +140,62 @@ func TestChecksDescriptions(t *testing.T) {
 	}
 }
 
-func TestCustom(t *testing.T) {
+func TestRegister(t *testing.T) {
+	// Not parallel since it modifies KnownChecks.
+	panics := func(f func()) (msg interface{}) {
+		defer func() {
+			msg = recover()
+		}()
+		f()
+		return nil
+	}
+	gofmt := func() Check { return &Gofmt{} }
+	ut.AssertEqual(t, "checks: Register called twice for check \"gofmt\"", panics(func() { Register("gofmt", gofmt) }))
+	ut.AssertEqual(t, "checks: Register called with \"foo\" for check \"gofmt\"", panics(func() { Register("foo", gofmt) }))
+	ut.AssertEqual(t, "checks: Register factory is nil", panics(func() { Register("foo", nil) }))
+
+	factory := func() Check { return &registered{} }
+	ut.AssertEqual(t, nil, panics(func() { Register("registered", factory) }))
+	defer delete(KnownChecks, "registered")
+	config := &Config{}
+	ut.AssertEqual(t, nil, yaml.Unmarshal([]byte("modes:\n  lint:\n    checks:\n      registered:\n      - {}\n"), config))
+	ut.AssertEqual(t, Checks{"registered": []Check{&registered{}}}, config.Modes[Lint].Checks)
+}
+
+func TestFormatGenerated(t *testing.T) {
+	// Generated files are not reported by gofmt and goimports.
 	t.Parallel()
-	p := []CheckPrerequisite{
-		{
-			HelpCommand:      []string{"go", "version"},
-			ExpectedExitCode: 0,
-			URL:              "example.com.local",
-		},
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	files := map[string]string{
+		"foo.go":        "package foo\n\nfunc Foo() int {\nreturn 1\n}\n",
+		"foo_string.go": "// Code generated by stringer; DO NOT EDIT.\n\npackage foo\n\nfunc Bar() int {\nreturn 2\n}\n",
 	}
-	c := &Custom{
-		Description:   "foo",
-		Command:       []string{"go", "version"},
-		Prerequisites: p,
+	change := setup(t, td, files)
+	expected := "these files are improperly formatted, please run: gofmt -w -s .\nfoo.go"
+	ut.AssertEqual(t, expected, (&Gofmt{}).Run(change, &Options{MaxDuration: 1}).Error())
+	if _, err := exec.LookPath("goimports"); err != nil {
+		t.Skip("goimports is not installed")
 	}
-	ut.AssertEqual(t, "foo", c.GetDescription())
-	ut.AssertEqual(t, p, c.GetPrerequisites())
+	expected = "these files are improperly formatted, please run: goimports -w <files>\nfoo.go"
+	ut.AssertEqual(t, expected, (&Goimports{}).Run(change, &Options{MaxDuration: 1}).Error())
 }
 
 // Private stuff.
 
+// registered is a check registered by TestRegister.
+type registered struct{}
+
+func (r *registered) GetDescription() string                        { return "registered" }
+func (r *registered) GetName() string                               { return "registered" }
+func (r *registered) GetPrerequisites() []CheckPrerequisite         { return nil }
+func (r *registered) Run(change scm.Change, options *Options) error { return nil }
+
 // This set of files passes all the tests.
 var goodFiles = map[string]string{
 	"foo.go": `// Foo
@@ -191,11 +239,19 @@ func main() {
 
 package foo
 
+//go:generate go invalid
+
 import "testing"
 
 func TestFail(t *testing.T) {
 t.Fail()
 }
+
+func FuzzFail(f *testing.F) {
+f.Fuzz(func(t *testing.T, b []byte) {
+t.Fail()
+})
+}
 `,
 }
 
@@ -8,6 +8,7 @@ package checks
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/maruel/pre-commit-go/internal"
@@ -20,7 +21,8 @@ import (
 type Mode string
 
 // All predefined modes are executed automatically based on the context, except
-// for Lint which needs to be selected manually.
+// for Lint which needs to be selected manually. Other modes can be defined in
+// pre-commit-go.yml and are selected manually.
 const (
 	PreCommit             Mode = "pre-commit"
 	PrePush               Mode = "pre-push"
@@ -28,7 +30,8 @@ const (
 	Lint                  Mode = "lint"
 )
 
-// AllModes are all known valid modes that can be used in pre-commit-go.yml.
+// AllModes are all the predefined modes. See Config.KnownModes() for the
+// modes including the user defined ones.
 var AllModes = []Mode{PreCommit, PrePush, ContinuousIntegration, Lint}
 
 // UnmarshalYAML implements yaml.Unmarshaler.
@@ -37,23 +40,24 @@ func (m *Mode) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&s); err != nil {
 		return err
 	}
-	val := Mode(s)
-	for _, known := range AllModes {
-		if val == known {
-			*m = val
-			return nil
-		}
+	if !reMode.MatchString(s) {
+		return fmt.Errorf("invalid mode \"%s\"", s)
 	}
-	return fmt.Errorf("invalid mode \"%s\"", val)
+	*m = Mode(s)
+	return nil
 }
 
 // Config is the serialized form of pre-commit-go.yml.
 type Config struct {
+	// Include are the configuration files to load before this one, relative to
+	// it. They are merged when loading the configuration files, see
+	// CONFIGURATION.md.
+	Include []string `yaml:"include,omitempty"`
 	// MinVersion is set to the current pcg version. Earlier version will refuse
 	// to load this file.
 	MinVersion string `yaml:"min_version"`
 	// Settings per mode. Settings includes the checks and the maximum allowed
-	// time spent to run them.
+	// time spent to run them. Modes other than AllModes are user defined.
 	Modes map[Mode]Settings `yaml:"modes"`
 	// IgnorePatterns is all paths glob patterns that should be ignored. By
 	// default, this include any file or directory starting with "." or "_", i.e.
@@ -72,11 +76,13 @@ func (c *Config) EnabledChecks(modes []Mode) ([]Check, *Options) {
 	options := &Options{}
 
 	for _, mode := range modes {
-		for _, checks := range c.Modes[mode].Checks {
-			out = append(out, checks...)
-		}
-		options = options.merge(c.Modes[mode].Options)
+		settings := c.ModeSettings(mode)
+		out = append(out, settings.scopedChecks()...)
+		options = options.merge(settings.Options)
 	}
+	options.modes = modes
+	options.ignorePatterns = c.IgnorePatterns
+	options.canceled = &canceler{c: make(chan struct{})}
 
 	if c.MaxConcurrent > 0 {
 		// Allocate and populate a run token semaphore.
@@ -87,10 +93,19 @@ func (c *Config) EnabledChecks(modes []Mode) ([]Check, *Options) {
 
 // Settings is the settings used for a mode.
 type Settings struct {
+	// Extends is the mode to inherit the checks and the options from, see
+	// Config.ModeSettings().
+	Extends Mode `yaml:"extends,omitempty"`
 	// Checks is a map of all checks enabled for this mode, with the key being
 	// the check type.
-	Checks  Checks  `yaml:"checks"`
-	Options Options `yaml:",inline"`
+	Checks Checks `yaml:"checks"`
+	// PerDir overrides the checks for a directory and its subdirectories,
+	// keyed by path relative to the repository root in POSIX format, "." being
+	// the root. The checks of a type listed for a directory replace the ones
+	// of the mode in this subtree and each check only receives the files and
+	// packages of the subtree it applies to.
+	PerDir  map[string]DirSettings `yaml:"per_dir,omitempty"`
+	Options Options                `yaml:",inline"`
 }
 
 // Options hold the settings for a mode shared by all checks.
@@ -98,11 +113,35 @@ type Options struct {
 	// MaxDuration is the maximum allowed duration to run all the checks in
 	// seconds. If it takes more time than that, it is marked as failed.
 	MaxDuration int `yaml:"max_duration"`
+	// FailOnUnusedSuppressions fails the run when a "//pcg:ignore" comment for
+	// a check that ran doesn't suppress any finding.
+	FailOnUnusedSuppressions bool `yaml:"fail_on_unused_suppressions"`
+	// FailFast cancels the remaining checks as soon as a check fails. The
+	// processes being run are killed and the checks not yet started are
+	// skipped.
+	FailFast bool `yaml:"fail_fast"`
+	// DependsOn maps a check name to the names of the checks that must succeed
+	// before it is started, e.g. so coverage only runs once gofmt passed. If a
+	// dependency fails, the check is skipped. Dependencies on checks that are
+	// not enabled are ignored.
+	DependsOn map[string][]string `yaml:"depends_on,omitempty"`
+	// Observer, if not nil, is notified of the checks and processes being run.
+	// It is not part of the configuration file.
+	Observer Observer `yaml:"-"`
 
 	// runTokens is a fixed-capacity semaphore channel.
 	//
 	// If nil, run token operations are no-ops.
 	runTokens chan struct{}
+	// canceled is closed by Cancel(). It is shared by the copies returned by
+	// ForCheck().
+	canceled *canceler
+	// modes are the modes being run and ignorePatterns the configured ignore
+	// patterns, passed to the custom checks using the json protocol.
+	modes          []Mode
+	ignorePatterns []string
+	// check is the check these options are passed to, see ForCheck().
+	check Check
 }
 
 // LeaseRunToken returns a leased run token.
@@ -125,26 +164,95 @@ func (o *Options) ReturnRunToken() {
 	<-o.runTokens
 }
 
+// Cancel kills the processes being run with these options and the copies
+// returned by ForCheck(). The processes started afterward are not run. It can
+// be called multiple times.
+func (o *Options) Cancel() {
+	if o.canceled == nil {
+		return
+	}
+	o.canceled.once.Do(func() { close(o.canceled.c) })
+}
+
 // Capture sets GOPATH and executes a subprocess.
 func (o *Options) Capture(r scm.ReadOnlyRepo, args ...string) (string, int, time.Duration, error) {
+	return o.captureIn(r.Root(), r.GOPATH(), args...)
+}
+
+// captureIn is like Capture but runs from directory wd with the specified
+// GOPATH. It is used by checks working on a copy of the tree.
+func (o *Options) captureIn(wd, gopath string, args ...string) (string, int, time.Duration, error) {
+	return o.captureEnv(wd, []string{"GOPATH=" + gopath}, args...)
+}
+
+// captureEnv is like captureIn but with arbitrary environment variables
+// overrides. An empty value removes the variable.
+func (o *Options) captureEnv(wd string, env []string, args ...string) (string, int, time.Duration, error) {
 	o.LeaseRunToken()
 	defer o.ReturnRunToken()
 
 	start := time.Now()
-	out, exitCode, err := internal.Capture(r.Root(), []string{"GOPATH=" + r.GOPATH()}, args...)
-	return out, exitCode, time.Since(start), err
+	finished := o.startProcess(args)
+	out, exitCode, err := internal.CaptureCancelable(o.cancelChan(), wd, env, args...)
+	duration := time.Since(start)
+	finished(exitCode, duration)
+	return out, exitCode, duration, err
+}
+
+// captureWithInput is like Capture but writes stdin to the process standard
+// input and returns its standard output and standard error separately.
+func (o *Options) captureWithInput(r scm.ReadOnlyRepo, stdin []byte, args ...string) (string, string, int, error) {
+	o.LeaseRunToken()
+	defer o.ReturnRunToken()
+
+	start := time.Now()
+	finished := o.startProcess(args)
+	stdout, stderr, exitCode, err := internal.CaptureWithInput(o.cancelChan(), r.Root(), []string{"GOPATH=" + r.GOPATH()}, stdin, args...)
+	finished(exitCode, time.Since(start))
+	return stdout, stderr, exitCode, err
 }
 
 // merge merges two options and returns a result.
 // This is used for multimode runs.
 func (o *Options) merge(r Options) *Options {
-	out := &Options{MaxDuration: o.MaxDuration}
+	out := &Options{
+		MaxDuration:              o.MaxDuration,
+		FailOnUnusedSuppressions: o.FailOnUnusedSuppressions || r.FailOnUnusedSuppressions,
+		FailFast:                 o.FailFast || r.FailFast,
+	}
 	if out.MaxDuration < r.MaxDuration {
 		out.MaxDuration = r.MaxDuration
 	}
+	for _, deps := range []map[string][]string{o.DependsOn, r.DependsOn} {
+		for name, names := range deps {
+			if out.DependsOn == nil {
+				out.DependsOn = map[string][]string{}
+			}
+			for _, n := range names {
+				if !containsString(out.DependsOn[name], n) {
+					out.DependsOn[name] = append(out.DependsOn[name], n)
+				}
+			}
+		}
+	}
 	return out
 }
 
+// cancelChan returns the channel closed by Cancel(), nil if the options
+// can't be canceled.
+func (o *Options) cancelChan() <-chan struct{} {
+	if o.canceled == nil {
+		return nil
+	}
+	return o.canceled.c
+}
+
+// canceler is closed once.
+type canceler struct {
+	once sync.Once
+	c    chan struct{}
+}
+
 // Checks helps with Check serialization.
 type Checks map[string][]Check
 
@@ -160,6 +268,8 @@ func (c *Checks) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		if !ok {
 			return fmt.Errorf("unknown check \"%s\"", checkTypeName)
 		}
+		// Keep empty lists, they remove the checks of an extended mode.
+		(*c)[checkTypeName] = []Check{}
 		for _, checkData := range checks {
 			rawCheckData, err := yaml.Marshal(checkData)
 			if err != nil {
@@ -268,18 +378,18 @@ func New(v string) *Config {
 					},
 					"govet": {
 						&Govet{
-							Blacklist: []string{" composite literal uses unkeyed fields"},
+							Analyzers:         []string{},
+							DisabledAnalyzers: []string{"composites"},
+							Blacklist:         []string{},
 						},
 					},
 				},
 			},
 		},
 		IgnorePatterns: []string{
-			"vendor",      // https://github.com/golang/go/wiki/PackageManagementTools
-			".*",          // SCM
-			"_*",          // Godeps
-			"*.pb.go",     // protobuf
-			"*_string.go", // stringer
+			"vendor", // https://github.com/golang/go/wiki/PackageManagementTools
+			".*",     // SCM
+			"_*",     // Godeps
 		},
 	}
 }
@@ -18,8 +18,8 @@ func TestConfigNew(t *testing.T) {
 	ut.AssertEqual(t, 3, len(config.Modes[PrePush].Checks))
 	ut.AssertEqual(t, 4, len(config.Modes[ContinuousIntegration].Checks))
 	ut.AssertEqual(t, 3, len(config.Modes[Lint].Checks))
-	checks, options := config.EnabledChecks([]Mode{PreCommit, PrePush, ContinuousIntegration, Lint})
-	ut.AssertEqual(t, Options{MaxDuration: 120}, *options)
+	checks, options := config.EnabledChecks(AllModes)
+	ut.AssertEqual(t, Options{MaxDuration: 120, modes: AllModes, ignorePatterns: config.IgnorePatterns, canceled: options.canceled}, *options)
 	ut.AssertEqual(t, 2+3+4+3, len(checks))
 }
 
@@ -33,9 +33,9 @@ func TestConfigYAML(t *testing.T) {
 }
 
 func TestConfigYAMLBadMode(t *testing.T) {
-	data, err := yaml.Marshal("foo")
+	data, err := yaml.Marshal("Foo bar")
 	ut.AssertEqual(t, nil, err)
 	v := PreCommit
-	ut.AssertEqual(t, errors.New("invalid mode \"foo\""), yaml.Unmarshal(data, &v))
+	ut.AssertEqual(t, errors.New("invalid mode \"Foo bar\""), yaml.Unmarshal(data, &v))
 	ut.AssertEqual(t, PreCommit, v)
 }
@@ -0,0 +1,289 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Copyright looks for copyright headers in all files.
+//
+// The header is expected at the top of the file. In Go files, it can be
+// preceded by build constraint lines, e.g. "//go:build linux". In other files,
+// it can be preceded by a "#!" line.
+type Copyright struct {
+	// Header is the expected header. "{year}" matches a year, a range or a list
+	// of years, e.g. "2016", "2014-2016" or "2014, 2016". "{holder}" matches
+	// the copyright holder.
+	Header string `yaml:"header"`
+	// Regexp is a regular expression the header must match, used instead of
+	// Header when set.
+	Regexp string `yaml:"regexp"`
+	// Holders is the list of accepted holders for "{holder}". Any holder is
+	// accepted when empty. The first one is used by 'pcg fix'.
+	Holders []string `yaml:"holders"`
+	// SkipGenerated skips the generated files, see scm.Change.IsGenerated().
+	SkipGenerated bool `yaml:"skip_generated"`
+	// Extensions is the list of extensions of non Go files to check, e.g.
+	// ".sh". The "//" starting the lines of Header are replaced with the line
+	// comment of the language, e.g. "#" for ".sh".
+	Extensions []string `yaml:"extensions"`
+}
+
+// GetDescription implements Check.
+func (c *Copyright) GetDescription() string {
+	if len(c.Extensions) == 0 {
+		return "enforces all .go sources have copyright"
+	}
+	return "enforces all .go sources and " + strings.Join(c.Extensions, ", ") + " files have copyright"
+}
+
+// GetName implements Check.
+func (c *Copyright) GetName() string {
+	return "copyright"
+}
+
+// GetPrerequisites implements Check.
+func (c *Copyright) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (c *Copyright) Run(change scm.Change, options *Options) error {
+	files := change.Changed().GoFiles()
+	if len(c.Extensions) != 0 {
+		files = nil
+		for _, f := range change.Changed().Files() {
+			if filepath.Ext(f) == ".go" || containsString(c.Extensions, filepath.Ext(f)) {
+				files = append(files, f)
+			}
+		}
+	}
+	headers := map[string]*regexp.Regexp{}
+	var badFiles []finding
+	// This this serially since it's I/O bound and will compete with process
+	// startup of other checks.
+	for _, f := range files {
+		if change.IsIgnored(f) {
+			continue
+		}
+		marker, err := commentMarker(f)
+		if err != nil {
+			return err
+		}
+		re := headers[marker]
+		if re == nil {
+			if re, err = c.compile(marker); err != nil {
+				return err
+			}
+			headers[marker] = re
+		}
+		content := change.Content(f)
+		if content == nil {
+			badFiles = append(badFiles, finding{path: f})
+			continue
+		}
+		if c.SkipGenerated && change.IsGenerated(f) {
+			continue
+		}
+		if !re.Match(skipPreamble(f, content)) {
+			badFiles = append(badFiles, finding{path: f})
+		}
+	}
+	return newFindingsError("files have invalid copyright header:", badFiles)
+}
+
+// validate implements validator.
+func (c *Copyright) validate() []*ConfigError {
+	var out []*ConfigError
+	for i, ext := range c.Extensions {
+		if _, ok := commentMarkers[ext]; !ok {
+			out = append(out, &ConfigError{Path: fmt.Sprintf("extensions[%d]", i), Message: fmt.Sprintf("unsupported extension \"%s\"", ext)})
+		}
+	}
+	return out
+}
+
+// fix implements fixer by inserting the header in the files missing it.
+//
+// Only headers without placeholder or whose placeholders can be expanded are
+// inserted.
+func (c *Copyright) fix(change scm.Change, options *Options, dir string, files []string) error {
+	header, ok := c.render()
+	if !ok {
+		log.Printf("copyright: can't render header to insert")
+		return nil
+	}
+	re, err := c.compile("//")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if c.SkipGenerated && change.IsGenerated(f) {
+			continue
+		}
+		p := filepath.Join(dir, f)
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rest := skipPreamble(f, content)
+		if re.Match(rest) {
+			continue
+		}
+		offset := len(content) - len(rest)
+		fixed := make([]byte, 0, len(content)+len(header)+2)
+		fixed = append(fixed, content[:offset]...)
+		fixed = append(fixed, header+"\n\n"...)
+		fixed = append(fixed, rest...)
+		if err := ioutil.WriteFile(p, fixed, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile returns the regexp matching the header at the start of a file using
+// marker for line comments.
+func (c *Copyright) compile(marker string) (*regexp.Regexp, error) {
+	if c.Regexp != "" {
+		re, err := regexp.Compile(`\A(?:` + c.Regexp + `)`)
+		if err != nil {
+			return nil, fmt.Errorf("copyright: invalid regexp: %s", err)
+		}
+		return re, nil
+	}
+	holder := `[^\n]+?`
+	if len(c.Holders) != 0 {
+		quoted := make([]string, len(c.Holders))
+		for i, h := range c.Holders {
+			quoted[i] = regexp.QuoteMeta(h)
+		}
+		holder = `(?:` + strings.Join(quoted, "|") + `)`
+	}
+	header := withCommentMarker(c.Header, marker)
+	pattern := `\A`
+	last := 0
+	for _, m := range reCopyrightPlaceholder.FindAllStringIndex(header, -1) {
+		pattern += regexp.QuoteMeta(header[last:m[0]])
+		if header[m[0]:m[1]] == "{year}" {
+			pattern += reYears
+		} else {
+			pattern += holder
+		}
+		last = m[1]
+	}
+	pattern += regexp.QuoteMeta(header[last:])
+	return regexp.Compile(pattern)
+}
+
+// render returns the header to insert in a file.
+func (c *Copyright) render() (string, bool) {
+	if c.Regexp != "" || c.Header == "" {
+		return "", false
+	}
+	if strings.Contains(c.Header, "{holder}") && len(c.Holders) == 0 {
+		return "", false
+	}
+	header := strings.Replace(c.Header, "{year}", strconv.Itoa(time.Now().Year()), -1)
+	if len(c.Holders) != 0 {
+		header = strings.Replace(header, "{holder}", c.Holders[0], -1)
+	}
+	return header, true
+}
+
+// Private stuff.
+
+// reCopyrightPlaceholder matches the placeholders in Copyright.Header.
+var reCopyrightPlaceholder = regexp.MustCompile(`\{(?:year|holder)\}`)
+
+// reYears matches a year, a range or a list of years.
+const reYears = `\d{4}(?:\s*[-,]\s*\d{4})*`
+
+// commentMarkers is the line comment per file extension, for the non Go files
+// checked by Copyright.
+var commentMarkers = map[string]string{
+	".bash":  "#",
+	".bzl":   "#",
+	".c":     "//",
+	".cc":    "//",
+	".cpp":   "//",
+	".go":    "//",
+	".h":     "//",
+	".hpp":   "//",
+	".java":  "//",
+	".js":    "//",
+	".lua":   "--",
+	".pl":    "#",
+	".proto": "//",
+	".py":    "#",
+	".rb":    "#",
+	".rs":    "//",
+	".s":     "//",
+	".sh":    "#",
+	".sql":   "--",
+	".swift": "//",
+	".toml":  "#",
+	".ts":    "//",
+	".yaml":  "#",
+	".yml":   "#",
+}
+
+// commentMarker returns the line comment for the file.
+func commentMarker(f string) (string, error) {
+	if m, ok := commentMarkers[filepath.Ext(f)]; ok {
+		return m, nil
+	}
+	return "", fmt.Errorf("copyright: unsupported extension %q", filepath.Ext(f))
+}
+
+// withCommentMarker replaces the "//" starting each line of header with
+// marker.
+func withCommentMarker(header, marker string) string {
+	if marker == "//" {
+		return header
+	}
+	lines := strings.Split(header, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, "//") {
+			lines[i] = marker + l[2:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// skipPreamble returns content without the lines that may precede the
+// copyright header: build constraints in Go files and "#!" in other files,
+// and the empty lines following them.
+func skipPreamble(f string, content []byte) []byte {
+	isGo := filepath.Ext(f) == ".go"
+	skipped := false
+	for i := 0; len(content) != 0; i++ {
+		line := content
+		rest := []byte(nil)
+		if n := bytes.IndexByte(content, '\n'); n != -1 {
+			line, rest = content[:n], content[n+1:]
+		}
+		trimmed := bytes.TrimSpace(line)
+		isPreamble := isGo && (bytes.HasPrefix(trimmed, []byte("//go:build")) || bytes.HasPrefix(trimmed, []byte("// +build")))
+		isPreamble = isPreamble || (!isGo && i == 0 && bytes.HasPrefix(line, []byte("#!")))
+		if !isPreamble && !(skipped && len(trimmed) == 0) {
+			break
+		}
+		skipped = true
+		content = rest
+	}
+	return content
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestCopyright(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"year.go":      "// Copyright 2016 Foo. All rights reserved.\n\npackage foo\n",
+		"range.go":     "// Copyright 2014-2016, 2018 Bar. All rights reserved.\n\npackage foo\n",
+		"tags.go":      "//go:build linux\n// +build linux\n\n// Copyright 2016 Foo. All rights reserved.\n\npackage foo\n",
+		"holder.go":    "// Copyright 2016 Baz. All rights reserved.\n\npackage foo\n",
+		"noyear.go":    "// Copyright Foo. All rights reserved.\n\npackage foo\n",
+		"missing.go":   "package foo\n",
+		"generated.go": "// Code generated by foo. DO NOT EDIT.\n\npackage foo\n",
+		"script.sh":    "#!/bin/sh\n# Copyright 2016 Foo. All rights reserved.\n",
+		"bad.sh":       "#!/bin/sh\necho hi\n",
+		"other.txt":    "not checked\n",
+	})
+
+	c := &Copyright{
+		Header:        "// Copyright {year} {holder}. All rights reserved.",
+		Holders:       []string{"Foo", "Bar"},
+		SkipGenerated: true,
+		Extensions:    []string{".sh"},
+	}
+	expected := &findingsError{"files have invalid copyright header:", []finding{
+		{path: "bad.sh"},
+		{path: "holder.go"},
+		{path: "missing.go"},
+		{path: "noyear.go"},
+	}}
+	ut.AssertEqual(t, expected, c.Run(change, &Options{}))
+
+	c = &Copyright{Regexp: `// Copyright \d+ (Foo|Baz)\.`}
+	expected = &findingsError{"files have invalid copyright header:", []finding{
+		{path: "generated.go"},
+		{path: "missing.go"},
+		{path: "noyear.go"},
+		{path: "range.go"},
+	}}
+	ut.AssertEqual(t, expected, c.Run(change, &Options{}))
+
+	c = &Copyright{Header: "// Foo", Extensions: []string{".sh", ".txt"}}
+	ut.AssertEqual(t, "enforces all .go sources and .sh, .txt files have copyright", c.GetDescription())
+	expectedErrs := []*ConfigError{{Path: "extensions[1]", Message: "unsupported extension \".txt\""}}
+	ut.AssertEqual(t, expectedErrs, c.validate())
+}
+
+func TestCopyrightFix(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	files := map[string]string{
+		"good.go":      "// Copyright 2016 Foo.\n\npackage foo\n",
+		"missing.go":   "package foo\n",
+		"tags.go":      "//go:build linux\n\npackage foo\n",
+		"generated.go": "// Code generated by foo. DO NOT EDIT.\n\npackage foo\n",
+	}
+	change := setup(t, td, files)
+	root := change.Repo().Root()
+	c := &Copyright{Header: "// Copyright {year} {holder}.", Holders: []string{"Foo"}, SkipGenerated: true}
+	ut.AssertEqual(t, nil, c.fix(change, &Options{}, root, []string{"generated.go", "good.go", "missing.go", "tags.go"}))
+
+	header := "// Copyright " + strconv.Itoa(time.Now().Year()) + " Foo.\n\n"
+	files["missing.go"] = header + "package foo\n"
+	files["tags.go"] = "//go:build linux\n\n" + header + "package foo\n"
+	for f, content := range files {
+		actual, err := ioutil.ReadFile(filepath.Join(root, f))
+		ut.AssertEqual(t, nil, err)
+		ut.AssertEqual(t, content, string(actual))
+	}
+
+	// {holder} can't be rendered without Holders.
+	c = &Copyright{Header: "// Copyright {year} {holder}."}
+	ut.AssertEqual(t, nil, ioutil.WriteFile(filepath.Join(root, "good.go"), []byte("package foo\n"), 0600))
+	ut.AssertEqual(t, nil, c.fix(change, &Options{}, root, []string{"good.go"}))
+	actual, err := ioutil.ReadFile(filepath.Join(root, "good.go"))
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, "package foo\n", string(actual))
+}
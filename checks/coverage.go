@@ -498,6 +498,38 @@ type FuncCovered struct {
 	Percent   float64
 }
 
+// validate implements validator.
+func (c *Coverage) validate() []*ConfigError {
+	out := c.Global.validate("global")
+	out = append(out, c.PerDirDefault.validate("per_dir_default")...)
+	dirs := make([]string, 0, len(c.PerDir))
+	for dir := range c.PerDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if s := c.PerDir[dir]; s != nil {
+			out = append(out, s.validate("per_dir."+dir)...)
+		}
+	}
+	return out
+}
+
+// validate returns the invalid coverage settings at path.
+func (s *CoverageSettings) validate(path string) []*ConfigError {
+	var out []*ConfigError
+	if s.MinCoverage < 0 || s.MinCoverage > 100 {
+		out = append(out, &ConfigError{Path: path + ".min_coverage", Message: "must be between 0 and 100"})
+	}
+	if s.MaxCoverage < 0 || s.MaxCoverage > 100 {
+		out = append(out, &ConfigError{Path: path + ".max_coverage", Message: "must be between 0 and 100"})
+	}
+	if s.MaxCoverage > 0 && s.MinCoverage > s.MaxCoverage {
+		out = append(out, &ConfigError{Path: path + ".min_coverage", Message: fmt.Sprintf("%.1f is greater than max_coverage %.1f", s.MinCoverage, s.MaxCoverage)})
+	}
+	return out
+}
+
 // Private stuff.
 
 func pkgToDir(p string) string {
@@ -623,6 +655,10 @@ func loadProfile(change limitedChange, r io.Reader) (CoverageProfile, error) {
 	for _, profile := range rawProfile {
 		// fn is in absolute package format based on $GOPATH. Transform to path.
 		source := profile.FileName[pkgOffset:]
+		if change.IsGenerated(source) {
+			// Generated code is not expected to be fully tested.
+			continue
+		}
 		content := change.Content(source)
 		if content == nil {
 			log.Printf("unknown file %s", source)
@@ -657,6 +693,7 @@ func loadProfile(change limitedChange, r io.Reader) (CoverageProfile, error) {
 // limitedChange is a subset of scm.Change
 type limitedChange interface {
 	IsIgnored(p string) bool
+	IsGenerated(p string) bool
 	Package() string
 	Content(p string) []byte
 }
@@ -677,6 +714,10 @@ func (f *filterPkg) Package() string {
 	return f.pkg
 }
 
+func (f *filterPkg) IsGenerated(p string) bool {
+	return f.change.IsGenerated(p)
+}
+
 func (f *filterPkg) Content(p string) []byte {
 	return f.change.Content(p)
 }
@@ -0,0 +1,348 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/checks/protocol"
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Custom represents a user configured check running an external program.
+//
+// It can be used multiple times to run multiple external checks.
+//
+// Command can contain placeholders that are expanded before running it:
+//   - "{repo_root}", "{package}" and "{gopath}" are replaced anywhere in an
+//     argument with scm.ReadOnlyRepo.Root(), scm.Change.Package() and
+//     scm.ReadOnlyRepo.GOPATH().
+//   - "{<set>.<list>}" where <set> is one of "changed", "indirect" or "all"
+//     and <list> is one of "files", "gofiles", "packages" or "testpackages"
+//     must be a whole argument and is replaced with as many arguments as
+//     there are items in the list, e.g. "{changed.gofiles}". See scm.Set.
+type Custom struct {
+	// DisplayName is check's display name, required.
+	DisplayName string `yaml:"display_name"`
+	// Description is check's description, optional.
+	Description string `yaml:"description"`
+	// Command is check's command line, required.
+	Command []string `yaml:"command"`
+	// CheckExitCode specifies if the check is declared to fail when exit code is
+	// non-zero.
+	CheckExitCode bool `yaml:"check_exit_code"`
+	// Prerequisites are check's prerequisite packages to install first before
+	// running the check, optional.
+	Prerequisites []CheckPrerequisite `yaml:"prerequisites"`
+	// BatchSize is the maximum number of items the list placeholder is
+	// expanded to per invocation, to stay under the command line length limit.
+	// The command is run once per batch. It requires Command to have a single
+	// list placeholder. 0 means no limit.
+	BatchSize int `yaml:"batch_size"`
+	// OutputFormat is the format of the command output, parsed into findings
+	// in the modified files. One of "gcc" ("file:line:column: message"),
+	// "checkstyle" (XML), "sarif" or "jsonl" (one json object per line with
+	// the keys "path", "line", "column", "rule" and "message"). When empty, the
+	// output is not parsed.
+	OutputFormat string `yaml:"output_format"`
+	// OutputRegexp is a regular expression matching a finding per line of
+	// output with the named groups "path", required, "line", "column", "rule"
+	// and "message". It is used instead of OutputFormat.
+	OutputRegexp string `yaml:"output_regexp"`
+	// Protocol is the version of the json protocol used to communicate with
+	// the command, see package protocol. When set, a description of the change
+	// is written to the command standard input and findings are read from its
+	// standard output. 0 means no protocol.
+	Protocol int `yaml:"protocol"`
+}
+
+// GetDescription implements Check.
+func (c *Custom) GetDescription() string {
+	if c.Description != "" {
+		return c.Description
+	}
+	return "runs a custom check from an external package"
+}
+
+// GetName implements Check.
+func (c *Custom) GetName() string {
+	return "custom"
+}
+
+// GetPrerequisites implements Check.
+func (c *Custom) GetPrerequisites() []CheckPrerequisite {
+	return c.Prerequisites
+}
+
+// Run implements Check.
+//
+// When Command has list placeholders and they all expand to nothing, e.g. no
+// Go file was modified, the command is not run.
+//
+// When the output is parsed, the check fails when there is a finding in a
+// modified file. With CheckExitCode, a non-zero exit code without any finding
+// in the output, e.g. when the tool crashed, also fails the check. With
+// Protocol, a non-zero exit code always fails the check.
+func (c *Custom) Run(change scm.Change, options *Options) error {
+	invocations, err := c.expand(change)
+	if err != nil {
+		return err
+	}
+	var re *regexp.Regexp
+	if c.OutputRegexp != "" {
+		if c.OutputFormat != "" {
+			return errors.New("custom: output_format and output_regexp are mutually exclusive")
+		}
+		if re, err = compileOutputRegexp(c.OutputRegexp); err != nil {
+			return fmt.Errorf("custom: invalid output_regexp: %s", err)
+		}
+	}
+	parsed := re != nil || c.OutputFormat != ""
+	var stdin []byte
+	if c.Protocol != 0 {
+		if c.Protocol != protocol.Version {
+			return fmt.Errorf("custom: unsupported protocol %d", c.Protocol)
+		}
+		if parsed {
+			return errors.New("custom: protocol and output_format or output_regexp are mutually exclusive")
+		}
+		if stdin, err = json.Marshal(newProtocolRequest(change, options)); err != nil {
+			return err
+		}
+	}
+	var failures []string
+	var findings []finding
+	for _, args := range invocations {
+		var out string
+		var exitCode int
+		var f []finding
+		if stdin != nil {
+			var stdout string
+			stdout, out, exitCode, err = options.captureWithInput(change.Repo(), stdin, args...)
+			if exitCode == 0 && err == nil {
+				var err2 error
+				if f, err2 = parseProtocolResponse(change, stdout); err2 != nil {
+					return fmt.Errorf("\"%s\" failed: %s\n%s%s", strings.Join(args, " "), err2, stdout, out)
+				}
+			}
+		} else {
+			out, exitCode, _, err = options.Capture(change.Repo(), args...)
+		}
+		if re != nil {
+			f = parseRegexp(change, re, out)
+		} else if parsed {
+			var err2 error
+			if f, err2 = parseOutput(change, c.OutputFormat, out); err2 != nil {
+				return fmt.Errorf("\"%s\" failed: %s\n%s", strings.Join(args, " "), err2, out)
+			}
+		}
+		if exitCode != 0 && (c.CheckExitCode || stdin != nil) && len(f) == 0 {
+			failures = append(failures, fmt.Sprintf("\"%s\" failed with code %d:\n%s", strings.Join(args, " "), exitCode, out))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		findings = append(findings, f...)
+	}
+	if len(failures) != 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	name := c.DisplayName
+	if name == "" {
+		name = c.Command[0]
+	}
+	return newFindingsError(name+" failed:", changedFindings(change, findings))
+}
+
+// expand returns the command lines to run, one per batch.
+func (c *Custom) expand(change scm.Change) ([][]string, error) {
+	if len(c.Command) == 0 {
+		return nil, errors.New("custom: command is required")
+	}
+	if c.BatchSize < 0 {
+		return nil, fmt.Errorf("custom: invalid batch_size %d", c.BatchSize)
+	}
+	repo := change.Repo()
+	scalars := strings.NewReplacer("{repo_root}", repo.Root(), "{package}", change.Package(), "{gopath}", repo.GOPATH())
+	var lists [][]string
+	listIndex := -1
+	listCount := 0
+	hasItems := false
+	for i, arg := range c.Command {
+		if list, ok := customList(change, arg); ok {
+			lists = append(lists, list)
+			listIndex = i
+			listCount++
+			hasItems = hasItems || len(list) != 0
+			continue
+		}
+		if m := reCustomList.FindString(arg); m != "" {
+			return nil, fmt.Errorf("custom: %s must be a whole argument in %q", m, arg)
+		}
+		lists = append(lists, []string{scalars.Replace(arg)})
+	}
+	if listIndex == -1 {
+		return [][]string{flatten(lists)}, nil
+	}
+	if !hasItems {
+		return nil, nil
+	}
+	if c.BatchSize == 0 {
+		return [][]string{flatten(lists)}, nil
+	}
+	if listCount != 1 {
+		return nil, errors.New("custom: batch_size requires a single list placeholder")
+	}
+	items := lists[listIndex]
+	var out [][]string
+	for len(items) != 0 {
+		n := c.BatchSize
+		if n > len(items) {
+			n = len(items)
+		}
+		lists[listIndex] = items[:n]
+		out = append(out, flatten(lists))
+		items = items[n:]
+	}
+	return out, nil
+}
+
+// Private stuff.
+
+// validate implements validator.
+func (c *Custom) validate() []*ConfigError {
+	var out []*ConfigError
+	if len(c.Command) == 0 {
+		out = append(out, &ConfigError{Path: "command", Message: "is required"})
+	}
+	if c.BatchSize < 0 {
+		out = append(out, &ConfigError{Path: "batch_size", Message: "must not be negative"})
+	}
+	switch c.OutputFormat {
+	case "", "checkstyle", "gcc", "jsonl", "sarif":
+	default:
+		out = append(out, &ConfigError{Path: "output_format", Message: fmt.Sprintf("unknown output format \"%s\"", c.OutputFormat)})
+	}
+	if c.OutputRegexp != "" {
+		if c.OutputFormat != "" {
+			out = append(out, &ConfigError{Path: "output_regexp", Message: "output_format and output_regexp are mutually exclusive"})
+		}
+		if _, err := compileOutputRegexp(c.OutputRegexp); err != nil {
+			out = append(out, &ConfigError{Path: "output_regexp", Message: err.Error()})
+		}
+	}
+	if c.Protocol != 0 && c.Protocol != protocol.Version {
+		out = append(out, &ConfigError{Path: "protocol", Message: fmt.Sprintf("unsupported protocol %d", c.Protocol)})
+	}
+	return out
+}
+
+// newProtocolRequest returns the description of the change sent to the
+// commands using the json protocol.
+func newProtocolRequest(change scm.Change, options *Options) *protocol.Request {
+	set := func(s scm.Set) protocol.Set {
+		return protocol.Set{
+			Files:        toSlash(s.Files()),
+			GoFiles:      toSlash(s.GoFiles()),
+			Packages:     toSlash(s.Packages()),
+			TestPackages: toSlash(s.TestPackages()),
+		}
+	}
+	req := &protocol.Request{
+		Version:        protocol.Version,
+		RepoRoot:       change.Repo().Root(),
+		Package:        change.Package(),
+		GOPATH:         change.Repo().GOPATH(),
+		Modes:          []string{},
+		IgnorePatterns: options.ignorePatterns,
+		Changed:        set(change.Changed()),
+		Indirect:       set(change.Indirect()),
+		All:            set(change.All()),
+	}
+	for _, m := range options.modes {
+		req.Modes = append(req.Modes, string(m))
+	}
+	if req.IgnorePatterns == nil {
+		req.IgnorePatterns = []string{}
+	}
+	return req
+}
+
+// parseProtocolResponse parses the json protocol response into findings in
+// the repository that are not in ignored files.
+func parseProtocolResponse(change scm.Change, stdout string) ([]finding, error) {
+	resp, err := protocol.ReadResponse(strings.NewReader(stdout))
+	if err != nil {
+		return nil, err
+	}
+	var findings []finding
+	for _, f := range resp.Findings {
+		if p := repoRelPath(change, f.Path); p != "" {
+			findings = append(findings, finding{p, f.Line, f.Column, f.Rule, f.Message, nil})
+		}
+	}
+	return sortFindings(findings), nil
+}
+
+// toSlash returns the paths with forward slashes, never nil so the lists are
+// serialized as json arrays.
+func toSlash(paths []string) []string {
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		out[i] = filepath.ToSlash(p)
+	}
+	return out
+}
+
+// reCustomList matches a list placeholder in Custom.Command.
+var reCustomList = regexp.MustCompile(`\{(?:changed|indirect|all)\.(?:files|gofiles|packages|testpackages)\}`)
+
+// customList returns the items of arg if it is a list placeholder.
+func customList(change scm.Change, arg string) ([]string, bool) {
+	if !strings.HasPrefix(arg, "{") || !strings.HasSuffix(arg, "}") {
+		return nil, false
+	}
+	parts := strings.SplitN(arg[1:len(arg)-1], ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	var set scm.Set
+	switch parts[0] {
+	case "changed":
+		set = change.Changed()
+	case "indirect":
+		set = change.Indirect()
+	case "all":
+		set = change.All()
+	default:
+		return nil, false
+	}
+	switch parts[1] {
+	case "files":
+		return set.Files(), true
+	case "gofiles":
+		return set.GoFiles(), true
+	case "packages":
+		return set.Packages(), true
+	case "testpackages":
+		return set.TestPackages(), true
+	}
+	return nil, false
+}
+
+// flatten concatenates lists.
+func flatten(lists [][]string) []string {
+	var out []string
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	return out
+}
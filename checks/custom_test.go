@@ -0,0 +1,163 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/checks/protocol"
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestCustom(t *testing.T) {
+	t.Parallel()
+	p := []CheckPrerequisite{
+		{
+			HelpCommand:      []string{"go", "version"},
+			ExpectedExitCode: 0,
+			URL:              "example.com.local",
+		},
+	}
+	c := &Custom{
+		Description:   "foo",
+		Command:       []string{"go", "version"},
+		Prerequisites: p,
+	}
+	ut.AssertEqual(t, "foo", c.GetDescription())
+	ut.AssertEqual(t, p, c.GetPrerequisites())
+}
+
+func TestCustomExpand(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go":          "package foo\n",
+		"bar/bar.go":      "package bar\n",
+		"bar/bar_test.go": "package bar\n",
+		"README.md":       "foo\n",
+	})
+	root := change.Repo().Root()
+	data := []struct {
+		c        Custom
+		expected [][]string
+	}{
+		{
+			Custom{Command: []string{"tool", "-C", "{repo_root}/bar", "{changed.gofiles}"}},
+			[][]string{{"tool", "-C", root + "/bar", "bar/bar.go", "bar/bar_test.go", "foo.go"}},
+		},
+		{
+			Custom{Command: []string{"tool", "{all.files}"}, BatchSize: 3},
+			[][]string{{"tool", "README.md", "bar/bar.go", "bar/bar_test.go"}, {"tool", "foo.go"}},
+		},
+		{
+			Custom{Command: []string{"tool", "{changed.packages}", "--", "{all.testpackages}"}},
+			[][]string{{"tool", ".", "./bar", "--", "./bar"}},
+		},
+		{
+			Custom{Command: []string{"tool", "{unknown.files}", "{changed.unknown}"}},
+			[][]string{{"tool", "{unknown.files}", "{changed.unknown}"}},
+		},
+	}
+	for i, line := range data {
+		actual, err := line.c.expand(change)
+		ut.AssertEqualIndex(t, i, nil, err)
+		ut.AssertEqualIndex(t, i, line.expected, actual)
+	}
+
+	_, err = (&Custom{Command: []string{"tool", "-files={changed.files}"}}).expand(change)
+	ut.AssertEqual(t, "custom: {changed.files} must be a whole argument in \"-files={changed.files}\"", err.Error())
+	_, err = (&Custom{Command: []string{"tool", "{changed.files}", "{all.files}"}, BatchSize: 1}).expand(change)
+	ut.AssertEqual(t, "custom: batch_size requires a single list placeholder", err.Error())
+}
+
+func TestCustomRun(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"README.md": "foo\n"})
+	// No Go file, the command is not run.
+	c := &Custom{Command: []string{"go", "invalid", "{changed.gofiles}"}, CheckExitCode: true}
+	ut.AssertEqual(t, nil, c.Run(change, &Options{}))
+	c = &Custom{Command: []string{"go", "invalid", "{changed.files}"}, CheckExitCode: true, BatchSize: 1}
+	ut.AssertEqual(t, true, c.Run(change, &Options{}) != nil)
+}
+
+func TestCustomOutput(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n"})
+	c := &Custom{
+		DisplayName:   "tool",
+		Command:       []string{"echo", "foo.go:1:1: bad\nunknown.go:1:1: not in the change"},
+		CheckExitCode: true,
+		OutputFormat:  "gcc",
+	}
+	ut.AssertEqual(t, "tool failed:\nfoo.go:1:1: bad", c.Run(change, &Options{}).Error())
+
+	c.OutputFormat = ""
+	c.OutputRegexp = `^(?P<path>unknown\.go):(?P<line>\d+)`
+	ut.AssertEqual(t, nil, c.Run(change, &Options{}))
+
+	c.OutputFormat = "gcc"
+	ut.AssertEqual(t, "custom: output_format and output_regexp are mutually exclusive", c.Run(change, &Options{}).Error())
+}
+
+func TestCustomProtocol(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n", "bar/bar.go": "package bar\n"})
+	options := &Options{modes: []Mode{PreCommit}}
+	expected := &protocol.Request{
+		Version:        1,
+		RepoRoot:       change.Repo().Root(),
+		Package:        "foo",
+		GOPATH:         td,
+		Modes:          []string{"pre-commit"},
+		IgnorePatterns: []string{},
+		Changed:        protocol.Set{Files: []string{"bar/bar.go", "foo.go"}, GoFiles: []string{"bar/bar.go", "foo.go"}, Packages: []string{".", "./bar"}, TestPackages: []string{}},
+	}
+	expected.Indirect = expected.Changed
+	expected.All = expected.Changed
+	ut.AssertEqual(t, expected, newProtocolRequest(change, options))
+
+	c := &Custom{
+		DisplayName: "tool",
+		Command:     []string{"echo", `{"version":1,"findings":[{"path":"foo.go","line":1,"message":"bad"},{"path":"unknown.go","message":"not in the change"}]}`},
+		Protocol:    1,
+	}
+	ut.AssertEqual(t, "tool failed:\nfoo.go:1:0: bad", c.Run(change, options).Error())
+	c.Command = []string{"echo", `{"version":2}`}
+	ut.AssertEqual(t, true, strings.Contains(c.Run(change, options).Error(), "unsupported protocol version 2, expected 1"))
+	c.Command = []string{"go", "invalid"}
+	ut.AssertEqual(t, true, strings.HasPrefix(c.Run(change, options).Error(), "\"go invalid\" failed with code 2:\n"))
+	c.Protocol = 2
+	ut.AssertEqual(t, "custom: unsupported protocol 2", c.Run(change, options).Error())
+}
@@ -0,0 +1,181 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// finding is an issue reported by a tool at a position in a file.
+type finding struct {
+	// path is relative to the repository root.
+	path string
+	// line is 0 when the finding is about the whole file.
+	line   int
+	column int
+	// rule is the analyzer, linter or code that reported the finding, if any.
+	rule    string
+	message string
+	fixes   []suggestedFix
+}
+
+func (f *finding) String() string {
+	out := f.path
+	if f.line != 0 {
+		out += fmt.Sprintf(":%d:%d", f.line, f.column)
+	}
+	if f.message != "" {
+		out += ": " + f.message
+	}
+	if f.rule != "" {
+		out += " (" + f.rule + ")"
+	}
+	for _, fix := range f.fixes {
+		out += "\n  suggested fix: " + fix.message
+	}
+	return out
+}
+
+// suggestedFix is a fix suggested by a tool for a finding.
+type suggestedFix struct {
+	message string
+	edits   []textEdit
+}
+
+// textEdit replaces the bytes [start, end) of the file path, relative to the
+// repository root, with newText.
+type textEdit struct {
+	path    string
+	start   int
+	end     int
+	newText string
+}
+
+// repoRelPath returns the path p relative to the repository root, or "" if it
+// is outside the repository or ignored. p is either relative to the root or
+// absolute, e.g. a source file in a stack trace, which may be in another
+// GOPATH entry pointing to the repository via a symlink.
+func repoRelPath(change scm.Change, p string) string {
+	if p == "" {
+		return ""
+	}
+	root := change.Repo().Root()
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = ""
+		if pkg := change.Package(); pkg != "" {
+			s := string(filepath.Separator) + "src" + string(filepath.Separator) + filepath.FromSlash(pkg) + string(filepath.Separator)
+			if i := strings.Index(p, s); i != -1 {
+				rel = p[i+len(s):]
+			}
+		}
+	}
+	if rel == "" || change.IsIgnored(rel) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// sortFindings sorts the findings by position and removes duplicates.
+func sortFindings(diags []finding) []finding {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].path != diags[j].path {
+			return diags[i].path < diags[j].path
+		}
+		if diags[i].line != diags[j].line {
+			return diags[i].line < diags[j].line
+		}
+		return diags[i].column < diags[j].column
+	})
+	var out []finding
+	seen := map[string]bool{}
+	for _, d := range diags {
+		if k := d.String(); !seen[k] {
+			seen[k] = true
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// findingsError is the error returned by a check reporting findings, so
+// suppressions can be applied to them.
+type findingsError struct {
+	// header is the first line of the message, e.g. "go vet failed:".
+	header   string
+	findings []finding
+}
+
+// newFindingsError returns a *findingsError or nil if there is no finding.
+func newFindingsError(header string, findings []finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	return &findingsError{header, findings}
+}
+
+func (e *findingsError) Error() string {
+	lines := make([]string, 0, len(e.findings)+1)
+	lines = append(lines, e.header)
+	for i := range e.findings {
+		lines = append(lines, e.findings[i].String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// changedFindings returns the sorted findings in the files modified by the
+// change, including non Go files. Findings in generated files are skipped since
+// they can't be fixed by hand.
+func changedFindings(change scm.Change, findings []finding) []finding {
+	files := map[string]bool{}
+	for _, f := range change.Changed().Files() {
+		files[filepath.ToSlash(f)] = true
+	}
+	var out []finding
+	for _, f := range sortFindings(findings) {
+		if files[f.path] && !change.IsGenerated(filepath.FromSlash(f.path)) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// parseFindings parses the output of a tool printing one finding per line
+// in the form "file:line:column: message" or "file:line: message". The file
+// may be absolute or relative to the repository root.
+//
+// Lines not matching are ignored, as are findings outside of the repository or
+// in ignored files.
+func parseFindings(change scm.Change, out, rule string) []finding {
+	var findings []finding
+	for _, line := range strings.Split(out, "\n") {
+		m := reFindingLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		p := repoRelPath(change, m[1])
+		if p == "" {
+			continue
+		}
+		l, _ := strconv.Atoi(m[2])
+		c, _ := strconv.Atoi(m[3])
+		findings = append(findings, finding{p, l, c, rule, strings.TrimSpace(m[4]), nil})
+	}
+	return findings
+}
+
+// reFindingLine matches "file:line:column: message" where the column is
+// optional.
+var reFindingLine = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?:\s*(.*)$`)
@@ -0,0 +1,183 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Fix computes the fixes that the checks can apply to the Go files modified by
+// the change and returns the new content of the files that would be modified,
+// keyed by path relative to the repository root. No file is modified.
+//
+// The fixes are the suggested fixes of the analyzers run by the govet and
+// analyzers checks, the insertion of the copyright header and the formatting
+// with goimports and gofmt, applied in this order. Generated files are left
+// alone since they would be overwritten by their generator.
+//
+// filter is called with the error returned by the Run() of the checks
+// suggesting fixes, so the findings it removes are not fixed. It is normally
+// used to honor the suppressions and the baseline. It can be nil.
+func Fix(change scm.Change, options *Options, checks []Check, filter func(check string, err error) error) (out map[string][]byte, err error) {
+	var files []string
+	for _, f := range change.Changed().GoFiles() {
+		if !change.IsIgnored(f) && !change.IsGenerated(f) && change.Content(f) != nil {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	// The files are fixed in a scratch directory so the tools fixing files in
+	// place can be used.
+	tmpDir, err2 := ioutil.TempDir("", "pre-commit-go")
+	if err2 != nil {
+		return nil, err2
+	}
+	defer func() {
+		err2 := internal.RemoveAll(tmpDir)
+		if err == nil {
+			err = err2
+		}
+	}()
+
+	// Suggested fixes are byte offsets in the original content so they are
+	// applied first.
+	var fixes []suggestedFix
+	for _, c := range checks {
+		switch unwrap(c).(type) {
+		case *Govet, *Analyzers:
+		default:
+			continue
+		}
+		err := c.Run(change, options)
+		if filter != nil {
+			err = filter(c.GetName(), err)
+		}
+		if fe, ok := err.(*findingsError); ok {
+			for _, f := range fe.findings {
+				// Multiple fixes of a finding are alternatives; use the first one.
+				if len(f.fixes) != 0 {
+					fixes = append(fixes, f.fixes[0])
+				}
+			}
+		} else if err != nil {
+			log.Printf("%s: no suggested fixes: %s", c.GetName(), err)
+		}
+	}
+	for _, f := range files {
+		content := applyFixes(filepath.ToSlash(f), change.Content(f), fixes)
+		p := filepath.Join(tmpDir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(p, content, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	// Then the fixes that rewrite the files, once per check type and per_dir
+	// subtree.
+	done := map[string]bool{}
+	for _, name := range []string{"copyright", "goimports", "gofmt"} {
+		for _, c := range checks {
+			scoped := files
+			key := name
+			if s, ok := c.(*scopedCheck); ok {
+				scoped = nil
+				for _, f := range files {
+					if s.contains(filepath.ToSlash(filepath.Dir(f))) {
+						scoped = append(scoped, f)
+					}
+				}
+				key += ":" + s.dir
+			}
+			if c.GetName() != name || done[key] || len(scoped) == 0 {
+				continue
+			}
+			done[key] = true
+			if err := unwrap(c).(fixer).fix(change, options, tmpDir, scoped); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out = map[string][]byte{}
+	for _, f := range files {
+		content, err := ioutil.ReadFile(filepath.Join(tmpDir, f))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(content, change.Content(f)) {
+			out[f] = content
+		}
+	}
+	return out, nil
+}
+
+// Private stuff.
+
+// fixer is implemented by the checks that can fix the files they report.
+type fixer interface {
+	// fix fixes in place files, relative to dir. dir contains a copy of the
+	// files modified by change.
+	fix(change scm.Change, options *Options, dir string, files []string) error
+}
+
+// applyFixes applies the edits of the fixes to the content of the file at
+// path, relative to the repository root.
+//
+// A fix is applied as a whole or not at all; fixes overlapping a fix already
+// applied are skipped. Identical edits, e.g. when the same analyzer is run by
+// two checks, are applied once.
+func applyFixes(path string, content []byte, fixes []suggestedFix) []byte {
+	var accepted []textEdit
+	for _, fix := range fixes {
+		var edits []textEdit
+		applies := true
+		for _, e := range fix.edits {
+			if e.path != path {
+				continue
+			}
+			if e.start < 0 || e.end < e.start || e.end > len(content) {
+				applies = false
+				break
+			}
+			duplicate := false
+			for _, a := range accepted {
+				if a == e {
+					duplicate = true
+					break
+				}
+				if e.start < a.end && a.start < e.end || (e.start == e.end && e.start == a.start) {
+					applies = false
+				}
+			}
+			if !duplicate {
+				edits = append(edits, e)
+			}
+		}
+		if applies {
+			accepted = append(accepted, edits...)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].start < accepted[j].start })
+	out := make([]byte, 0, len(content))
+	last := 0
+	for _, e := range accepted {
+		out = append(out, content[last:e.start]...)
+		out = append(out, e.newText...)
+		last = e.end
+	}
+	return append(out, content[last:]...)
+}
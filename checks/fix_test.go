@@ -0,0 +1,77 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestApplyFixes(t *testing.T) {
+	t.Parallel()
+	content := []byte("0123456789")
+	fixes := []suggestedFix{
+		{"replace", []textEdit{{"foo.go", 1, 3, "ab"}, {"foo.go", 8, 9, ""}}},
+		// Overlaps the first fix.
+		{"overlap", []textEdit{{"foo.go", 2, 4, "X"}, {"foo.go", 5, 5, "Y"}}},
+		// Same as the first fix.
+		{"replace", []textEdit{{"foo.go", 1, 3, "ab"}}},
+		{"insert", []textEdit{{"foo.go", 5, 5, "Z"}, {"bar.go", 0, 0, "ignored"}}},
+		{"out of bounds", []textEdit{{"foo.go", 9, 11, ""}}},
+	}
+	ut.AssertEqual(t, "0ab34Z5679", string(applyFixes("foo.go", content, fixes)))
+	ut.AssertEqual(t, "0123456789", string(applyFixes("baz.go", content, fixes)))
+}
+
+func TestFix(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"good.go": "// Foo\n\npackage foo\n",
+		"bad.go":  "package foo\nfunc  bar() {\n}\n",
+	})
+	checks := []Check{&Gofmt{}, &Copyright{Header: "// Foo"}, &Copyright{Header: "// Bar"}}
+	fixed, err := Fix(change, &Options{}, checks, nil)
+	ut.AssertEqual(t, nil, err)
+	expected := map[string][]byte{
+		"bad.go": []byte("// Foo\n\npackage foo\n\nfunc bar() {\n}\n"),
+	}
+	ut.AssertEqual(t, expected, fixed)
+}
+
+func TestFixGoimports(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("goimports"); err != nil {
+		t.Skip("goimports is not installed")
+	}
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	// foo/bar is only found in the GOPATH of the repository.
+	change := setup(t, td, map[string]string{
+		"bar/bar.go": "package bar\n\nfunc B() int { return 1 }\n",
+		"foo.go":     "package foo\n\nfunc F() int {\n\treturn bar.B()\n}\n",
+	})
+	fixed, err := Fix(change, &Options{}, []Check{&Goimports{}}, nil)
+	ut.AssertEqual(t, nil, err)
+	expected := map[string][]byte{
+		"foo.go": []byte("package foo\n\nimport \"foo/bar\"\n\nfunc F() int {\n\treturn bar.B()\n}\n"),
+	}
+	ut.AssertEqual(t, expected, fixed)
+}
@@ -0,0 +1,206 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// parseOutput parses out in the format and returns the findings in the
+// repository that are not in ignored files.
+func parseOutput(change scm.Change, format, out string) ([]finding, error) {
+	switch format {
+	case "checkstyle":
+		return parseCheckstyle(change, out)
+	case "gcc":
+		return parseFindings(change, out, ""), nil
+	case "jsonl":
+		return parseJSONLines(change, out)
+	case "sarif":
+		return parseSARIF(change, out)
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// parseCheckstyle parses a checkstyle XML report.
+//
+// Anything before the XML document, e.g. log lines on stderr, is skipped. An
+// output without document has no finding.
+func parseCheckstyle(change scm.Change, out string) ([]finding, error) {
+	var report struct {
+		Files []struct {
+			Name   string `xml:"name,attr"`
+			Errors []struct {
+				Line    int    `xml:"line,attr"`
+				Column  int    `xml:"column,attr"`
+				Message string `xml:"message,attr"`
+				Source  string `xml:"source,attr"`
+			} `xml:"error"`
+		} `xml:"file"`
+	}
+	i := strings.Index(out, "<")
+	if i == -1 {
+		return nil, nil
+	}
+	if err := xml.Unmarshal([]byte(out[i:]), &report); err != nil {
+		return nil, fmt.Errorf("invalid checkstyle report: %s", err)
+	}
+	var findings []finding
+	for _, f := range report.Files {
+		p := repoRelPath(change, f.Name)
+		if p == "" {
+			continue
+		}
+		for _, e := range f.Errors {
+			findings = append(findings, finding{p, e.Line, e.Column, e.Source, e.Message, nil})
+		}
+	}
+	return sortFindings(findings), nil
+}
+
+// parseSARIF parses a SARIF 2.1 log.
+//
+// Anything before the json document is skipped and an output without document
+// has no finding. Results without a physical location are ignored. Relative
+// URIs are relative to the repository root.
+func parseSARIF(change scm.Change, out string) ([]finding, error) {
+	var log struct {
+		Runs []struct {
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	i := strings.Index(out, "{")
+	if i == -1 {
+		return nil, nil
+	}
+	if err := json.NewDecoder(strings.NewReader(out[i:])).Decode(&log); err != nil {
+		return nil, fmt.Errorf("invalid SARIF log: %s", err)
+	}
+	var findings []finding
+	for _, run := range log.Runs {
+		for _, r := range run.Results {
+			for _, l := range r.Locations {
+				u, err := url.Parse(l.PhysicalLocation.ArtifactLocation.URI)
+				if err != nil || u.Path == "" {
+					continue
+				}
+				if p := repoRelPath(change, u.Path); p != "" {
+					region := l.PhysicalLocation.Region
+					findings = append(findings, finding{p, region.StartLine, region.StartColumn, r.RuleID, r.Message.Text, nil})
+				}
+			}
+		}
+	}
+	return sortFindings(findings), nil
+}
+
+// parseJSONLines parses one json object per line with the keys "path",
+// "line", "column", "rule" and "message". "path" is required.
+//
+// Lines not starting with "{", e.g. log lines on stderr, are skipped.
+func parseJSONLines(change scm.Change, out string) ([]finding, error) {
+	var findings []finding
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var item struct {
+			Path    string `json:"path"`
+			Line    int    `json:"line"`
+			Column  int    `json:"column"`
+			Rule    string `json:"rule"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			return nil, fmt.Errorf("invalid json line %q: %s", line, err)
+		}
+		if item.Path == "" {
+			return nil, fmt.Errorf("json line without path: %q", line)
+		}
+		if p := repoRelPath(change, item.Path); p != "" {
+			findings = append(findings, finding{p, item.Line, item.Column, item.Rule, item.Message, nil})
+		}
+	}
+	return sortFindings(findings), nil
+}
+
+// compileOutputRegexp compiles a regular expression matching a finding per
+// line with the named groups "path", "line", "column", "rule" and "message".
+func compileOutputRegexp(s string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+	hasPath := false
+	for _, name := range re.SubexpNames() {
+		switch name {
+		case "path":
+			hasPath = true
+		case "", "line", "column", "rule", "message":
+		default:
+			return nil, fmt.Errorf("unknown group %q", name)
+		}
+	}
+	if !hasPath {
+		return nil, errors.New("missing group \"path\"")
+	}
+	return re, nil
+}
+
+// parseRegexp parses the lines of out matching re, as returned by
+// compileOutputRegexp.
+func parseRegexp(change scm.Change, re *regexp.Regexp, out string) []finding {
+	var findings []finding
+	for _, line := range strings.Split(out, "\n") {
+		m := re.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		var f finding
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "path":
+				f.path = m[i]
+			case "line":
+				f.line, _ = strconv.Atoi(m[i])
+			case "column":
+				f.column, _ = strconv.Atoi(m[i])
+			case "rule":
+				f.rule = m[i]
+			case "message":
+				f.message = strings.TrimSpace(m[i])
+			}
+		}
+		if f.path = repoRelPath(change, f.path); f.path != "" {
+			findings = append(findings, f)
+		}
+	}
+	return sortFindings(findings)
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestParseOutput(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n", "doc.md": "foo\n"})
+	abs := filepath.Join(change.Repo().Root(), "foo.go")
+	expected := []finding{
+		{"doc.md", 0, 0, "", "whole file", nil},
+		{"foo.go", 3, 2, "R1", "bad", nil},
+	}
+	data := []struct {
+		format string
+		out    string
+	}{
+		{"gcc", "doc.md:0: whole file\n" + abs + ":3:2: bad\n/elsewhere.go:1:1: outside\n"},
+		{
+			"checkstyle",
+			"log line\n<?xml version=\"1.0\"?>\n<checkstyle version=\"5.0\">\n" +
+				"<file name=\"doc.md\"><error message=\"whole file\"/></file>\n" +
+				"<file name=\"" + abs + "\"><error line=\"3\" column=\"2\" severity=\"error\" message=\"bad\" source=\"R1\"/></file>\n" +
+				"</checkstyle>\n",
+		},
+		{
+			"sarif",
+			`{"version":"2.1.0","runs":[{"results":[` +
+				`{"ruleId":"R1","message":{"text":"bad"},"locations":[{"physicalLocation":{"artifactLocation":{"uri":"file://` + filepath.ToSlash(abs) + `"},"region":{"startLine":3,"startColumn":2}}}]},` +
+				`{"message":{"text":"whole file"},"locations":[{"physicalLocation":{"artifactLocation":{"uri":"doc.md"}}}]},` +
+				`{"message":{"text":"no location"}}` +
+				`]}]}`,
+		},
+		{
+			"jsonl",
+			"log line\n" +
+				`{"path":"foo.go","line":3,"column":2,"rule":"R1","message":"bad"}` + "\n" +
+				`{"path":"doc.md","message":"whole file"}` + "\n",
+		},
+	}
+	for i, line := range data {
+		actual, err := parseOutput(change, line.format, line.out)
+		ut.AssertEqualIndex(t, i, nil, err)
+		if line.format == "gcc" {
+			// The rule can't be parsed.
+			for j := range actual {
+				actual[j].rule = expected[j].rule
+			}
+		}
+		ut.AssertEqualIndex(t, i, expected, actual)
+	}
+
+	actual, err := parseOutput(change, "sarif", "")
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []finding(nil), actual)
+	_, err = parseOutput(change, "jsonl", `{"line":1}`)
+	ut.AssertEqual(t, "json line without path: \"{\\\"line\\\":1}\"", err.Error())
+	_, err = parseOutput(change, "unknown", "")
+	ut.AssertEqual(t, "unknown output format \"unknown\"", err.Error())
+
+	re, err := compileOutputRegexp(`^(?P<rule>\w+) (?P<path>[^:]+):(?P<line>\d+): (?P<message>.*)$`)
+	ut.AssertEqual(t, nil, err)
+	expected = []finding{{"foo.go", 3, 0, "R1", "bad", nil}}
+	ut.AssertEqual(t, expected, parseRegexp(change, re, "R1 foo.go:3: bad\nnot matching\n"))
+	_, err = compileOutputRegexp(`(?P<file>.+)`)
+	ut.AssertEqual(t, "unknown group \"file\"", err.Error())
+	_, err = compileOutputRegexp(`(?P<line>\d+)`)
+	ut.AssertEqual(t, "missing group \"path\"", err.Error())
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Fuzz runs each native fuzz target found in the modified test packages for
+// a short duration.
+//
+// The Test check only runs the seed corpus of fuzz targets. When a failing
+// input is found, go test writes it in the package's testdata/fuzz directory;
+// the check reports its path so it can be committed as a regression test.
+type Fuzz struct {
+	// FuzzTime is the value passed to -fuzztime for each fuzz target, e.g. "10s"
+	// or "1000x". Defaults to "10s".
+	FuzzTime string `yaml:"fuzz_time"`
+	// ExtraArgs are additional arguments to pass to go test.
+	ExtraArgs []string `yaml:"extra_args"`
+}
+
+// GetDescription implements Check.
+func (f *Fuzz) GetDescription() string {
+	return "runs the fuzz targets of modified test packages for a short duration"
+}
+
+// GetName implements Check.
+func (f *Fuzz) GetName() string {
+	return "fuzz"
+}
+
+// GetPrerequisites implements Check.
+func (f *Fuzz) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (f *Fuzz) Run(change scm.Change, options *Options) error {
+	fuzzTime := f.FuzzTime
+	if fuzzTime == "" {
+		fuzzTime = "10s"
+	}
+	// go test -fuzz only accepts one package and one target at a time and uses
+	// all the CPUs, so run the targets serially.
+	var errs []string
+	for _, testPkg := range change.Changed().TestPackages() {
+		for _, target := range findFuzzTargets(change, testPkg) {
+			args := append(
+				[]string{
+					"go", "test", "-run=^$",
+					"-fuzz", "^" + target + "$",
+					"-fuzztime", fuzzTime,
+					"-timeout", fmt.Sprintf("%ds", options.MaxDuration),
+				},
+				f.ExtraArgs...)
+			args = append(args, testPkg)
+			out, exitCode, duration, _ := options.Capture(change.Repo(), args...)
+			if duration > time.Second {
+				log.Printf("%s was slow: %s", args, round(duration, time.Millisecond))
+			}
+			if exitCode == 0 {
+				continue
+			}
+			if input := parseFuzzFailingInput(out); input != "" {
+				errs = append(errs, fmt.Sprintf("%s in %s found a failing input; commit %s to keep it as a regression test:\n%s", target, testPkg, path.Join(pkgToDir(testPkg), input), processStackTrace(out)))
+			} else {
+				errs = append(errs, fmt.Sprintf("%s failed:\n%s", strings.Join(args, " "), processStackTrace(out)))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// validate implements validator.
+func (f *Fuzz) validate() []*ConfigError {
+	if f.FuzzTime == "" {
+		return nil
+	}
+	if n := strings.TrimSuffix(f.FuzzTime, "x"); n != f.FuzzTime {
+		if i, err := strconv.Atoi(n); err != nil || i <= 0 {
+			return []*ConfigError{{Path: "fuzz_time", Message: fmt.Sprintf("invalid number of iterations \"%s\"", f.FuzzTime)}}
+		}
+		return nil
+	}
+	if d, err := time.ParseDuration(f.FuzzTime); err != nil {
+		return []*ConfigError{{Path: "fuzz_time", Message: err.Error()}}
+	} else if d <= 0 {
+		return []*ConfigError{{Path: "fuzz_time", Message: "must be positive"}}
+	}
+	return nil
+}
+
+// Private stuff.
+
+// reFuzzFailingInput matches the line printed by go test when it saved a
+// failing input, e.g. "Failing input written to testdata/fuzz/FuzzFoo/1234".
+var reFuzzFailingInput = regexp.MustCompile(`Failing input written to (\S+)`)
+
+// parseFuzzFailingInput returns the path relative to the package directory of
+// the failing input saved by go test, if any.
+func parseFuzzFailingInput(out string) string {
+	if m := reFuzzFailingInput.FindStringSubmatch(out); m != nil {
+		return filepath.ToSlash(m[1])
+	}
+	return ""
+}
+
+// findFuzzTargets returns the sorted name of all the fuzz targets in the test
+// files of the package testPkg.
+func findFuzzTargets(change scm.Change, testPkg string) []string {
+	dir := pkgToDir(testPkg)
+	var targets []string
+	for _, f := range change.All().GoFiles() {
+		if !strings.HasSuffix(f, "_test.go") || filepath.ToSlash(filepath.Dir(f)) != dir || change.IsIgnored(f) {
+			continue
+		}
+		targets = append(targets, getFuzzTargets(f, change.Content(f))...)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// getFuzzTargets returns the top level functions in the form
+// "func FuzzXxx(*testing.F)".
+func getFuzzTargets(name string, content []byte) []string {
+	if content == nil {
+		return nil
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), name, content, 0)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Fuzz") {
+			continue
+		}
+		params := fn.Type.Params.List
+		if len(params) != 1 || len(params[0].Names) > 1 {
+			continue
+		}
+		star, ok := params[0].Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+		if sel, ok := star.X.(*ast.SelectorExpr); ok && sel.Sel.Name == "F" {
+			out = append(out, fn.Name.Name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/maruel/ut"
+)
+
+func TestGetFuzzTargets(t *testing.T) {
+	t.Parallel()
+	content := `package foo
+
+import "testing"
+
+func FuzzFoo(f *testing.F) {}
+
+func FuzzNotATarget(t *testing.T) {}
+
+func (x *y) FuzzMethod(f *testing.F) {}
+
+func fuzzLower(f *testing.F) {}
+
+func FuzzBar(f *testing.F) {}
+`
+	ut.AssertEqual(t, []string{"FuzzFoo", "FuzzBar"}, getFuzzTargets("foo_test.go", []byte(content)))
+	ut.AssertEqual(t, []string(nil), getFuzzTargets("foo_test.go", []byte("package")))
+	ut.AssertEqual(t, []string(nil), getFuzzTargets("foo_test.go", nil))
+}
+
+func TestParseFuzzFailingInput(t *testing.T) {
+	t.Parallel()
+	out := "--- FAIL: FuzzFoo (0.01s)\n" +
+		"    --- FAIL: FuzzFoo (0.00s)\n" +
+		"        foo_test.go:9: boom\n" +
+		"    \n" +
+		"    Failing input written to testdata/fuzz/FuzzFoo/b5249c1d9920948e\n" +
+		"    To re-run:\n" +
+		"    go test -run=FuzzFoo/b5249c1d9920948e\n" +
+		"FAIL\n"
+	ut.AssertEqual(t, "testdata/fuzz/FuzzFoo/b5249c1d9920948e", parseFuzzFailingInput(out))
+	ut.AssertEqual(t, "", parseFuzzFailingInput("PASS\n"))
+}
@@ -0,0 +1,195 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Generate runs go generate on the modified packages in a copy of the tree
+// and fails when a generated file differs from the one in the tree, e.g. when
+// it wasn't regenerated after its source was modified.
+type Generate struct {
+	// ExtraArgs are additional arguments to pass to go generate, e.g. "-run" and
+	// "stringer".
+	ExtraArgs []string `yaml:"extra_args"`
+}
+
+// GetDescription implements Check.
+func (g *Generate) GetDescription() string {
+	return "enforces the files generated by go generate are up to date"
+}
+
+// GetName implements Check.
+func (g *Generate) GetName() string {
+	return "generate"
+}
+
+// GetPrerequisites implements Check.
+func (g *Generate) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (g *Generate) Run(change scm.Change, options *Options) (err error) {
+	directives := findGenerateDirectives(change)
+	if len(directives) == 0 {
+		return nil
+	}
+	var pkgs []string
+	for dir := range directives {
+		pkgs = append(pkgs, dirToPackage(dir))
+	}
+	sort.Strings(pkgs)
+
+	tmpDir, err2 := ioutil.TempDir("", "pre-commit-go")
+	if err2 != nil {
+		return err2
+	}
+	defer func() {
+		err2 := internal.RemoveAll(tmpDir)
+		if err == nil {
+			err = err2
+		}
+	}()
+	root, gopath, err := exportTree(change, scm.Current, tmpDir)
+	if err != nil {
+		return err
+	}
+	args := append(append([]string{"go", "generate"}, g.ExtraArgs...), pkgs...)
+	out, exitCode, _, err := options.captureIn(root, gopath, args...)
+	if exitCode != 0 || err != nil {
+		return fmt.Errorf("%s failed: %s\n%s", strings.Join(args, " "), err, out)
+	}
+
+	var stale []finding
+	var missing []string
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || change.IsIgnored(rel) {
+			return err
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		old := change.Content(rel)
+		if old != nil && bytes.Equal(old, content) {
+			return nil
+		}
+		msg := "is stale"
+		if old == nil {
+			msg = "is missing"
+			missing = append(missing, rel)
+		}
+		sources := directives[filepath.Dir(rel)].producing(rel)
+		if len(sources) != 0 {
+			msg += ", generated by " + strings.Join(sources, " or ")
+		}
+		stale = append(stale, finding{path: filepath.ToSlash(rel), message: msg})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The missing files ignored by the source control, e.g. build artifacts left
+	// by the generators, are not expected in the checkout.
+	if ignored := change.Repo().Ignored(missing); len(ignored) != 0 {
+		skip := map[string]bool{}
+		for _, f := range ignored {
+			skip[filepath.ToSlash(f)] = true
+		}
+		var out []finding
+		for _, f := range stale {
+			if !skip[f.path] {
+				out = append(out, f)
+			}
+		}
+		stale = out
+	}
+	return newFindingsError("generated files are not up to date, please run: "+strings.Join(args, " "), sortFindings(stale))
+}
+
+// Private stuff.
+
+// generateDirective is a //go:generate line.
+type generateDirective struct {
+	path    string
+	line    int
+	command string
+}
+
+// generateDirectives are the directives in a directory.
+type generateDirectives []generateDirective
+
+// producing returns the directives that likely generated the file at path,
+// formatted as strings.
+//
+// These are the directives whose command mentions the file name or its stem,
+// or all the directives in the directory when none does.
+func (g generateDirectives) producing(path string) []string {
+	base := filepath.Base(path)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	var all, matching []string
+	for _, d := range g {
+		s := fmt.Sprintf("%q in %s:%d", "//go:generate "+d.command, filepath.ToSlash(d.path), d.line)
+		all = append(all, s)
+		if strings.Contains(d.command, base) || strings.Contains(d.command, stem) {
+			matching = append(matching, s)
+		}
+	}
+	if len(matching) != 0 {
+		return matching
+	}
+	return all
+}
+
+// findGenerateDirectives returns the //go:generate directives in the modified
+// packages, per directory.
+//
+// All the files in the packages are scanned since modifying any file of the
+// package, e.g. the declaration of a type passed to stringer, may make the
+// generated files stale.
+func findGenerateDirectives(change scm.Change) map[string]generateDirectives {
+	dirs := map[string]bool{}
+	for _, pkg := range change.Changed().Packages() {
+		dirs[filepath.FromSlash(strings.TrimPrefix(pkg, "./"))] = true
+	}
+	out := map[string]generateDirectives{}
+	for _, f := range change.All().GoFiles() {
+		dir := filepath.Dir(f)
+		if !dirs[dir] || change.IsIgnored(f) {
+			continue
+		}
+		for i, line := range strings.Split(string(change.Content(f)), "\n") {
+			if strings.HasPrefix(line, "//go:generate ") {
+				cmd := strings.TrimSpace(strings.TrimPrefix(line, "//go:generate "))
+				out[dir] = append(out[dir], generateDirective{f, i + 1, cmd})
+			}
+		}
+	}
+	return out
+}
+
+// dirToPackage returns the relative package for a directory relative to the
+// repository root, e.g. "./foo/bar".
+func dirToPackage(dir string) string {
+	if dir == "." {
+		return dir
+	}
+	return "./" + filepath.ToSlash(dir)
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+	data := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{
+			map[string]string{"out_gen.go": generated(2)},
+			"",
+		},
+		{
+			map[string]string{"out_gen.go": generated(1)},
+			"generated files are not up to date, please run: go generate .\n" +
+				"out_gen.go: is stale, generated by \"//go:generate go run gen.go\" in foo.go:3",
+		},
+		{
+			map[string]string{},
+			"generated files are not up to date, please run: go generate .\n" +
+				"out_gen.go: is missing, generated by \"//go:generate go run gen.go\" in foo.go:3",
+		},
+	}
+	for i, line := range data {
+		td, err := ioutil.TempDir("", "pre-commit-go")
+		ut.AssertEqual(t, nil, err)
+		line.files["foo.go"] = "package foo\n\n//go:generate go run gen.go\n\ntype T int\n"
+		line.files["gen.go"] = "//go:build ignore\n\npackage main\n\nimport \"io/ioutil\"\n\nfunc main() {\n\tif err := ioutil.WriteFile(\"out_gen.go\", []byte(" +
+			"\"// Code generated by gen.go. DO NOT EDIT.\\n\\npackage foo\\n\\nconst N = 2\\n\"), 0644); err != nil {\n\t\tpanic(err)\n\t}\n}\n"
+		change := setup(t, td, line.files)
+		err = (&Generate{}).Run(change, &Options{})
+		if line.expected == "" {
+			ut.AssertEqualIndex(t, i, nil, err)
+		} else {
+			ut.AssertEqualIndex(t, i, line.expected, err.Error())
+		}
+		ut.AssertEqual(t, nil, internal.RemoveAll(td))
+	}
+}
+
+func TestGenerateIgnored(t *testing.T) {
+	// The files written by a directive that are ignored by git are not reported.
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		".gitignore": "*.cache\n",
+		"foo.go":     "package foo\n\n//go:generate go run gen.go\n",
+		"gen.go": "//go:build ignore\n\npackage main\n\nimport \"io/ioutil\"\n\nfunc main() {\n" +
+			"\tif err := ioutil.WriteFile(\"gen.cache\", nil, 0644); err != nil {\n\t\tpanic(err)\n\t}\n" +
+			"\tif err := ioutil.WriteFile(\"out.txt\", nil, 0644); err != nil {\n\t\tpanic(err)\n\t}\n}\n",
+	})
+	expected := "generated files are not up to date, please run: go generate .\n" +
+		"out.txt: is missing, generated by \"//go:generate go run gen.go\" in foo.go:3"
+	ut.AssertEqual(t, expected, (&Generate{}).Run(change, &Options{}).Error())
+}
+
+func TestGenerateFailure(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go": "package foo\n\n//go:generate go invalid\n",
+	})
+	ut.AssertEqual(t, true, (&Generate{}).Run(change, &Options{}) != nil)
+	ut.AssertEqual(t, nil, (&Generate{ExtraArgs: []string{"-run", "stringer"}}).Run(change, &Options{}))
+}
+
+// Private stuff.
+
+func generated(n int) string {
+	return "// Code generated by gen.go. DO NOT EDIT.\n\npackage foo\n\nconst N = " + strconv.Itoa(n) + "\n"
+}
@@ -0,0 +1,225 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/appends"
+	"golang.org/x/tools/go/analysis/passes/asmdecl"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/buildtag"
+	"golang.org/x/tools/go/analysis/passes/cgocall"
+	"golang.org/x/tools/go/analysis/passes/composite"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/defers"
+	"golang.org/x/tools/go/analysis/passes/directive"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
+	"golang.org/x/tools/go/analysis/passes/framepointer"
+	"golang.org/x/tools/go/analysis/passes/hostport"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/ifaceassert"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/sigchanyzer"
+	"golang.org/x/tools/go/analysis/passes/slog"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/stdversion"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/testinggoroutine"
+	"golang.org/x/tools/go/analysis/passes/tests"
+	"golang.org/x/tools/go/analysis/passes/timeformat"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+	"golang.org/x/tools/go/analysis/passes/waitgroup"
+)
+
+// Govet runs the analyzers of go vet in process on the packages affected by
+// the change.
+type Govet struct {
+	// Analyzers is the list of analyzers to run by name, e.g. "printf". It
+	// defaults to all the analyzers run by go vet when empty.
+	Analyzers []string `yaml:"analyzers"`
+	// DisabledAnalyzers is the list of analyzers not to run by name, e.g.
+	// "composites".
+	DisabledAnalyzers []string `yaml:"disabled_analyzers"`
+	// Blacklist is the list of substrings of the diagnostics to ignore, e.g.
+	// "struct literal uses unkeyed fields".
+	Blacklist []string `yaml:"blacklist"`
+}
+
+// GetDescription implements Check.
+func (g *Govet) GetDescription() string {
+	return "enforces all .go sources pass the go vet analyzers"
+}
+
+// GetName implements Check.
+func (g *Govet) GetName() string {
+	return "govet"
+}
+
+// GetPrerequisites implements Check.
+func (g *Govet) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (g *Govet) Run(change scm.Change, options *Options) error {
+	analyzers, err := g.analyzers()
+	if err != nil {
+		return err
+	}
+	pkgs := change.Indirect().Packages()
+	if len(analyzers) == 0 || len(pkgs) == 0 {
+		return nil
+	}
+	diags, err := runAnalyzers(change, options, analyzers, pkgs)
+	if err != nil {
+		return err
+	}
+	var out []finding
+	for _, d := range changedFindings(change, diags) {
+		if !g.isBlacklisted(&d) {
+			out = append(out, d)
+		}
+	}
+	return newFindingsError("go vet failed:", out)
+}
+
+// analyzers returns the analyzers to run, with the disabled ones removed.
+func (g *Govet) analyzers() ([]*analysis.Analyzer, error) {
+	byName := map[string]*analysis.Analyzer{}
+	for _, a := range govetAnalyzers {
+		byName[a.Name] = a
+	}
+	disabled := g.DisabledAnalyzers
+	for _, name := range disabled {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("govet: unknown analyzer %q in disabled_analyzers", name)
+		}
+	}
+	for _, b := range g.Blacklist {
+		if name := govetLegacyMessages[strings.TrimSpace(b)]; name != "" {
+			log.Printf("govet: blacklist %q is the message of go tool vet, disabling analyzer %q instead; use disabled_analyzers", b, name)
+			disabled = append(disabled, name)
+		}
+	}
+	var out []*analysis.Analyzer
+	if len(g.Analyzers) == 0 {
+		out = govetAnalyzers
+	} else {
+		for _, name := range g.Analyzers {
+			a := byName[name]
+			if a == nil {
+				return nil, fmt.Errorf("govet: unknown analyzer %q", name)
+			}
+			out = append(out, a)
+		}
+	}
+	var filtered []*analysis.Analyzer
+	for _, a := range out {
+		if !containsString(disabled, a.Name) {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered, nil
+}
+
+// validate implements validator.
+func (g *Govet) validate() []*ConfigError {
+	var out []*ConfigError
+	for _, field := range []struct {
+		path  string
+		names []string
+	}{{"analyzers", g.Analyzers}, {"disabled_analyzers", g.DisabledAnalyzers}} {
+		for i, name := range field.names {
+			if !isGovetAnalyzer(name) {
+				out = append(out, &ConfigError{Path: fmt.Sprintf("%s[%d]", field.path, i), Message: fmt.Sprintf("unknown analyzer \"%s\"", name)})
+			}
+		}
+	}
+	return out
+}
+
+// Private stuff.
+
+// isBlacklisted returns true if the diagnostic d contains one of the
+// substrings of Blacklist.
+func (g *Govet) isBlacklisted(d *finding) bool {
+	s := d.String()
+	for _, b := range g.Blacklist {
+		if b != "" && strings.Contains(s, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// govetLegacyMessages maps the messages of the former "go tool vet", used in
+// Blacklist by the configuration files written by older versions, to the
+// analyzer reporting them now.
+var govetLegacyMessages = map[string]string{
+	"composite literal uses unkeyed fields": "composites",
+}
+
+func isGovetAnalyzer(name string) bool {
+	for _, a := range govetAnalyzers {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// govetAnalyzers are the analyzers run by go vet.
+var govetAnalyzers = []*analysis.Analyzer{
+	appends.Analyzer,
+	asmdecl.Analyzer,
+	assign.Analyzer,
+	atomic.Analyzer,
+	bools.Analyzer,
+	buildtag.Analyzer,
+	cgocall.Analyzer,
+	composite.Analyzer,
+	copylock.Analyzer,
+	defers.Analyzer,
+	directive.Analyzer,
+	errorsas.Analyzer,
+	framepointer.Analyzer,
+	httpresponse.Analyzer,
+	hostport.Analyzer,
+	ifaceassert.Analyzer,
+	loopclosure.Analyzer,
+	lostcancel.Analyzer,
+	nilfunc.Analyzer,
+	printf.Analyzer,
+	shift.Analyzer,
+	sigchanyzer.Analyzer,
+	slog.Analyzer,
+	stdmethods.Analyzer,
+	stdversion.Analyzer,
+	stringintconv.Analyzer,
+	structtag.Analyzer,
+	tests.Analyzer,
+	testinggoroutine.Analyzer,
+	timeformat.Analyzer,
+	unmarshal.Analyzer,
+	unreachable.Analyzer,
+	unsafeptr.Analyzer,
+	unusedresult.Analyzer,
+	waitgroup.Analyzer,
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGovet(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go":      "package foo\n\nimport \"fmt\"\n\ntype T struct{ A, B int }\n\nfunc Foo() string {\n\tfmt.Printf(\"%d\\n\", \"s\")\n\treturn fmt.Sprint(T{1, 2})\n}\n",
+		"foo_test.go": "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Logf(\"%s\", 1)\n}\n",
+		// Findings in generated files are skipped.
+		"gen.go": "// Code generated by hand. DO NOT EDIT.\n\npackage foo\n\nimport \"fmt\"\n\nfunc Gen() {\n\tfmt.Printf(\"%d\\n\", \"s\")\n}\n",
+	})
+	options := &Options{MaxDuration: 60}
+	expected := "go vet failed:\n" +
+		"foo.go:8:14: fmt.Printf format %d has arg \"s\" of wrong type string (printf)\n" +
+		"foo_test.go:6:10: (*testing.common).Logf format %s has arg 1 of wrong type int (printf)"
+	ut.AssertEqual(t, expected, (&Govet{}).Run(change, options).Error())
+	ut.AssertEqual(t, nil, (&Govet{DisabledAnalyzers: []string{"printf"}}).Run(change, options))
+	ut.AssertEqual(t, nil, (&Govet{Analyzers: []string{"composites"}}).Run(change, options))
+	expected = "go vet failed:\n" +
+		"foo.go:8:14: fmt.Printf format %d has arg \"s\" of wrong type string (printf)"
+	ut.AssertEqual(t, expected, (&Govet{Blacklist: []string{"Logf format"}}).Run(change, options).Error())
+}
+
+func TestGovetLegacyConfig(t *testing.T) {
+	t.Parallel()
+	// The govet settings written by "pcg writeconfig" before the analyzers were
+	// run in process.
+	data := `modes:
+  lint:
+    checks:
+      govet:
+      - blacklist:
+        - ' composite literal uses unkeyed fields'
+`
+	ut.AssertEqual(t, []*ConfigError(nil), ValidateConfig([]byte(data)))
+	config := &Config{}
+	ut.AssertEqual(t, nil, yaml.Unmarshal([]byte(data), config))
+	g := config.Modes[Lint].Checks["govet"][0].(*Govet)
+	a, err := g.analyzers()
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, len(govetAnalyzers)-1, len(a))
+	for _, analyzer := range a {
+		ut.AssertEqual(t, false, analyzer.Name == "composites")
+	}
+}
+
+func TestGovetUnknownAnalyzer(t *testing.T) {
+	t.Parallel()
+	_, err := (&Govet{Analyzers: []string{"foo"}}).analyzers()
+	ut.AssertEqual(t, errors.New("govet: unknown analyzer \"foo\""), err)
+	_, err = (&Govet{DisabledAnalyzers: []string{"bar"}}).analyzers()
+	ut.AssertEqual(t, errors.New("govet: unknown analyzer \"bar\" in disabled_analyzers"), err)
+	a, err := (&Govet{Analyzers: []string{"printf", "shift"}, DisabledAnalyzers: []string{"shift"}}).analyzers()
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, 1, len(a))
+	ut.AssertEqual(t, "printf", a[0].Name)
+	expected := []*ConfigError{
+		{Path: "analyzers[0]", Message: "unknown analyzer \"foo\""},
+		{Path: "disabled_analyzers[1]", Message: "unknown analyzer \"bar\""},
+	}
+	ut.AssertEqual(t, expected, (&Govet{Analyzers: []string{"foo"}, DisabledAnalyzers: []string{"shift", "bar"}}).validate())
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Staticcheck runs staticcheck on the modified packages.
+//
+// staticcheck.conf files in the repository are honored.
+type Staticcheck struct {
+	// Checks is the list of checks to enable or disable, passed to -checks,
+	// e.g. "all" and "-ST1000". Defaults to staticcheck's configuration when
+	// empty.
+	Checks []string `yaml:"checks"`
+}
+
+// GetDescription implements Check.
+func (s *Staticcheck) GetDescription() string {
+	return "enforces all .go sources passes staticcheck"
+}
+
+// GetName implements Check.
+func (s *Staticcheck) GetName() string {
+	return "staticcheck"
+}
+
+// GetPrerequisites implements Check.
+func (s *Staticcheck) GetPrerequisites() []CheckPrerequisite {
+	return []CheckPrerequisite{
+		{[]string{"staticcheck", "-version"}, 0, "honnef.co/go/tools/cmd/staticcheck"},
+	}
+}
+
+// Run implements Check.
+func (s *Staticcheck) Run(change scm.Change, options *Options) error {
+	// - accepts packages, not files.
+	// - returns non-zero on report.
+	// - accepts multiple packages per call.
+	pkgs := change.Changed().Packages()
+	if len(pkgs) == 0 {
+		return nil
+	}
+	args := []string{"staticcheck", "-f", "json"}
+	if len(s.Checks) != 0 {
+		args = append(args, "-checks", strings.Join(s.Checks, ","))
+	}
+	out, _, _, err := options.Capture(change.Repo(), append(args, pkgs...)...)
+	if err != nil {
+		return fmt.Errorf("%s failed: %s", strings.Join(args, " "), err)
+	}
+	findings, err := parseStaticcheckJSON(change, out)
+	if err != nil {
+		return fmt.Errorf("%s failed:\n%s", strings.Join(args, " "), err)
+	}
+	return newFindingsError("staticcheck failed:", changedFindings(change, findings))
+}
+
+// GolangciLint runs golangci-lint on the modified packages.
+type GolangciLint struct {
+	// Config is the path to the golangci-lint configuration file, relative to
+	// the repository root. Defaults to golangci-lint's own lookup, e.g.
+	// .golangci.yml.
+	Config string `yaml:"config"`
+	// Linters is the list of linters to run, passed to --enable-only. Defaults
+	// to the linters enabled in the configuration file when empty.
+	Linters []string `yaml:"linters"`
+}
+
+// GetDescription implements Check.
+func (g *GolangciLint) GetDescription() string {
+	return "enforces all .go sources passes golangci-lint"
+}
+
+// GetName implements Check.
+func (g *GolangciLint) GetName() string {
+	return "golangci_lint"
+}
+
+// GetPrerequisites implements Check.
+func (g *GolangciLint) GetPrerequisites() []CheckPrerequisite {
+	return []CheckPrerequisite{
+		{[]string{"golangci-lint", "--version"}, 0, "github.com/golangci/golangci-lint/v2/cmd/golangci-lint"},
+	}
+}
+
+// Run implements Check.
+func (g *GolangciLint) Run(change scm.Change, options *Options) error {
+	// - accepts packages, not files.
+	// - returns non-zero on report.
+	// - accepts multiple packages per call.
+	pkgs := change.Changed().Packages()
+	if len(pkgs) == 0 {
+		return nil
+	}
+	args := []string{"golangci-lint", "run", "--output.json.path=stdout", "--show-stats=false"}
+	if g.Config != "" {
+		args = append(args, "--config", g.Config)
+	}
+	if len(g.Linters) != 0 {
+		args = append(args, "--enable-only", strings.Join(g.Linters, ","))
+	}
+	out, _, _, err := options.Capture(change.Repo(), append(args, pkgs...)...)
+	if err != nil {
+		return fmt.Errorf("%s failed: %s", strings.Join(args, " "), err)
+	}
+	findings, err := parseGolangciJSON(change, out)
+	if err != nil {
+		return fmt.Errorf("%s failed:\n%s", strings.Join(args, " "), out)
+	}
+	return newFindingsError("golangci-lint failed:", changedFindings(change, findings))
+}
+
+// Private stuff.
+
+// parseStaticcheckJSON parses the output of "staticcheck -f json", which is
+// one json object per finding.
+//
+// Findings without a location, like a package failing to compile, are
+// returned as an error.
+func parseStaticcheckJSON(change scm.Change, out string) ([]finding, error) {
+	var findings []finding
+	d := json.NewDecoder(strings.NewReader(out))
+	for {
+		var item struct {
+			Code     string `json:"code"`
+			Location struct {
+				File   string `json:"file"`
+				Line   int    `json:"line"`
+				Column int    `json:"column"`
+			} `json:"location"`
+			Message string `json:"message"`
+		}
+		if err := d.Decode(&item); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s\n%s", err, out)
+		}
+		if item.Location.File == "" {
+			return nil, errors.New(item.Message)
+		}
+		if p := repoRelPath(change, item.Location.File); p != "" {
+			findings = append(findings, finding{p, item.Location.Line, item.Location.Column, item.Code, item.Message, nil})
+		}
+	}
+	return sortFindings(findings), nil
+}
+
+// parseGolangciJSON parses the output of golangci-lint with the json output
+// on stdout.
+//
+// The output is mixed with the log lines on stderr, so the report is the line
+// starting with "{".
+func parseGolangciJSON(change scm.Change, out string) ([]finding, error) {
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var report struct {
+			Issues []struct {
+				FromLinter string
+				Text       string
+				Pos        struct {
+					Filename string
+					Line     int
+					Column   int
+				}
+			}
+		}
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			return nil, err
+		}
+		var findings []finding
+		for _, i := range report.Issues {
+			if p := repoRelPath(change, i.Pos.Filename); p != "" {
+				findings = append(findings, finding{p, i.Pos.Line, i.Pos.Column, i.FromLinter, i.Text, nil})
+			}
+		}
+		return sortFindings(findings), nil
+	}
+	return nil, errors.New("no json report found")
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestParseStaticcheckJSON(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n"})
+	root := filepath.ToSlash(change.Repo().Root())
+	out := `{"code":"SA4017","severity":"error","location":{"file":"` + root + `/foo.go","line":11,"column":2},"end":{"file":"` + root + `/foo.go","line":11,"column":22},"message":"Sprintf doesn't have side effects and its return value is ignored"}
+{"code":"SA4006","severity":"error","location":{"file":"` + root + `/foo.go","line":3,"column":1},"message":"this value of x is never used"}
+{"code":"U1000","severity":"error","location":{"file":"/elsewhere/bar.go","line":1,"column":1},"message":"outside"}
+`
+	findings, err := parseStaticcheckJSON(change, out)
+	ut.AssertEqual(t, nil, err)
+	expected := []finding{
+		{"foo.go", 3, 1, "SA4006", "this value of x is never used", nil},
+		{"foo.go", 11, 2, "SA4017", "Sprintf doesn't have side effects and its return value is ignored", nil},
+	}
+	ut.AssertEqual(t, expected, findings)
+
+	out = `{"code":"compile","severity":"error","location":{"file":"","line":0,"column":0},"message":"# foo\n./foo.go:10:9: too many return values"}` + "\n"
+	_, err = parseStaticcheckJSON(change, out)
+	ut.AssertEqual(t, errors.New("# foo\n./foo.go:10:9: too many return values"), err)
+}
+
+func TestParseGolangciJSON(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n"})
+	out := "level=warning msg=\"[runner] something\"\n" +
+		`{"Issues":[{"FromLinter":"govet","Text":"unusedresult: result of fmt.Sprintf call not used","Severity":"","SourceLines":["\tfmt.Sprintf(\"%d\", x)"],"Pos":{"Filename":"foo.go","Offset":122,"Line":11,"Column":2}},{"FromLinter":"errcheck","Text":"Error return value is not checked","Pos":{"Filename":"/elsewhere/bar.go","Line":1,"Column":1}}],"Report":{"Linters":[{"Name":"govet","Enabled":true}]}}` + "\n"
+	findings, err := parseGolangciJSON(change, out)
+	ut.AssertEqual(t, nil, err)
+	expected := []finding{{"foo.go", 11, 2, "govet", "unusedresult: result of fmt.Sprintf call not used", nil}}
+	ut.AssertEqual(t, expected, findings)
+
+	_, err = parseGolangciJSON(change, "Error: unknown flag: --foo\n")
+	ut.AssertEqual(t, errors.New("no json report found"), err)
+}
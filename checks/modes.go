@@ -0,0 +1,149 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// KnownModes returns the predefined modes followed by the user defined modes
+// in c, sorted.
+func (c *Config) KnownModes() []Mode {
+	out := append([]Mode{}, AllModes...)
+	var user []string
+	for mode := range c.Modes {
+		if !mode.isPredefined() {
+			user = append(user, string(mode))
+		}
+	}
+	sort.Strings(user)
+	for _, m := range user {
+		out = append(out, Mode(m))
+	}
+	return out
+}
+
+// ModeSettings returns the settings of mode with the checks and options of the
+// mode it extends, if any, applied.
+//
+// The checks of a type listed in mode replace the checks of the same type in
+// the extended mode; an empty list removes them. The per_dir directories,
+// max_duration and the entries of depends_on set in mode override the ones of
+// the extended mode. fail_fast and fail_on_unused_suppressions are sticky:
+// they are enabled when enabled in either mode, as a false value can't be
+// told apart from an unset one, so a mode can't disable them.
+func (c *Config) ModeSettings(mode Mode) Settings {
+	return c.modeSettings(mode, map[Mode]bool{})
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+//
+// It verifies that the modes extend known modes without cycle and that the
+// per_dir directories are valid.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// plainConfig doesn't have UnmarshalYAML, so it doesn't recurse.
+	if err := unmarshal((*plainConfig)(c)); err != nil {
+		return err
+	}
+	if err := c.checkExtends(); err != nil {
+		return err
+	}
+	return c.checkPerDir()
+}
+
+// Private stuff.
+
+// reMode is the valid format of a mode name.
+var reMode = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// isPredefined returns true if m is one of AllModes.
+func (m Mode) isPredefined() bool {
+	for _, known := range AllModes {
+		if m == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) modeSettings(mode Mode, seen map[Mode]bool) Settings {
+	s := c.Modes[mode]
+	if s.Extends == "" || seen[mode] {
+		return s
+	}
+	seen[mode] = true
+	parent := c.modeSettings(s.Extends, seen)
+	out := Settings{
+		Extends: s.Extends,
+		Checks:  Checks{},
+		Options: inheritOptions(parent.Options, s.Options),
+	}
+	for name, l := range parent.Checks {
+		out.Checks[name] = l
+	}
+	for name, l := range s.Checks {
+		if len(l) == 0 {
+			delete(out.Checks, name)
+		} else {
+			out.Checks[name] = l
+		}
+	}
+	if len(parent.PerDir) != 0 || len(s.PerDir) != 0 {
+		out.PerDir = map[string]DirSettings{}
+		for dir, d := range parent.PerDir {
+			out.PerDir[dir] = d
+		}
+		for dir, d := range s.PerDir {
+			out.PerDir[dir] = d
+		}
+	}
+	return out
+}
+
+// checkExtends returns an error if a mode extends an unknown mode or if there
+// is a cycle.
+func (c *Config) checkExtends() error {
+	for _, mode := range c.KnownModes() {
+		path := []string{string(mode)}
+		seen := map[Mode]bool{mode: true}
+		for m := mode; c.Modes[m].Extends != ""; {
+			parent := c.Modes[m].Extends
+			if _, ok := c.Modes[parent]; !ok {
+				return fmt.Errorf("mode \"%s\" extends unknown mode \"%s\"", m, parent)
+			}
+			path = append(path, string(parent))
+			if seen[parent] {
+				return fmt.Errorf("modes extends cycle: %s", strings.Join(path, " -> "))
+			}
+			seen[parent] = true
+			m = parent
+		}
+	}
+	return nil
+}
+
+// inheritOptions returns the options of a mode extending a mode with options
+// parent. The booleans are ORed, see ModeSettings.
+func inheritOptions(parent, child Options) Options {
+	out := child
+	if out.MaxDuration == 0 {
+		out.MaxDuration = parent.MaxDuration
+	}
+	out.FailOnUnusedSuppressions = parent.FailOnUnusedSuppressions || child.FailOnUnusedSuppressions
+	out.FailFast = parent.FailFast || child.FailFast
+	if len(parent.DependsOn) != 0 {
+		out.DependsOn = map[string][]string{}
+		for name, deps := range parent.DependsOn {
+			out.DependsOn[name] = deps
+		}
+		for name, deps := range child.DependsOn {
+			out.DependsOn[name] = deps
+		}
+	}
+	return out
+}
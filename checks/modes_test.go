@@ -0,0 +1,87 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maruel/ut"
+	"gopkg.in/yaml.v2"
+)
+
+func TestModesExtends(t *testing.T) {
+	t.Parallel()
+	data := `modes:
+  continuous-integration:
+    checks:
+      gofmt:
+      - {}
+      test:
+      - extra_args: [-short]
+    max_duration: 60
+    fail_fast: true
+  nightly:
+    extends: continuous-integration
+    checks:
+      test:
+      - extra_args: [-race]
+      copyright:
+      - header: "// Copyright"
+    max_duration: 600
+    # Sticky, it can't be disabled.
+    fail_fast: false
+  security:
+    extends: nightly
+    checks:
+      gofmt: []
+`
+	config := &Config{}
+	ut.AssertEqual(t, nil, yaml.Unmarshal([]byte(data), config))
+	ut.AssertEqual(t, []Mode{PreCommit, PrePush, ContinuousIntegration, Lint, "nightly", "security"}, config.KnownModes())
+
+	nightly := config.ModeSettings("nightly")
+	ut.AssertEqual(t, Mode(ContinuousIntegration), nightly.Extends)
+	ut.AssertEqual(t, Options{MaxDuration: 600, FailFast: true}, nightly.Options)
+	ut.AssertEqual(t, 3, len(nightly.Checks))
+	ut.AssertEqual(t, []string{"-race"}, nightly.Checks["test"][0].(*Test).ExtraArgs)
+
+	security := config.ModeSettings("security")
+	ut.AssertEqual(t, Options{MaxDuration: 600, FailFast: true}, security.Options)
+	ut.AssertEqual(t, 2, len(security.Checks))
+	ut.AssertEqual(t, 0, len(security.Checks["gofmt"]))
+
+	enabled, options := config.EnabledChecks([]Mode{"security"})
+	ut.AssertEqual(t, 2, len(enabled))
+	ut.AssertEqual(t, 600, options.MaxDuration)
+
+	// The extended mode is not modified.
+	ut.AssertEqual(t, 2, len(config.ModeSettings(ContinuousIntegration).Checks))
+}
+
+func TestModesExtendsInvalid(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in       string
+		expected error
+	}{
+		{
+			"modes:\n  nightly:\n    extends: foo\n",
+			errors.New("mode \"nightly\" extends unknown mode \"foo\""),
+		},
+		{
+			"modes:\n  a:\n    extends: b\n  b:\n    extends: a\n",
+			errors.New("modes extends cycle: a -> b -> a"),
+		},
+		{
+			"modes:\n  a:\n    extends: a\n",
+			errors.New("modes extends cycle: a -> a"),
+		},
+	}
+	for i, line := range data {
+		config := &Config{}
+		ut.AssertEqualIndex(t, i, line.expected, yaml.Unmarshal([]byte(line.in), config))
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Modules verifies the go modules containing modified files in a copy of the
+// tree: 'go mod tidy' makes no change, go.sum is complete, 'go mod verify'
+// passes and, when the module is vendored, vendor/ matches 'go mod vendor'.
+type Modules struct {
+	// SkipVerify skips 'go mod verify', which checks the module cache, e.g.
+	// when the modules are not downloaded on the machine.
+	SkipVerify bool `yaml:"skip_verify"`
+}
+
+// GetDescription implements Check.
+func (m *Modules) GetDescription() string {
+	return "enforces go.mod, go.sum and vendor/ are tidy and verified"
+}
+
+// GetName implements Check.
+func (m *Modules) GetName() string {
+	return "modules"
+}
+
+// GetPrerequisites implements Check.
+func (m *Modules) GetPrerequisites() []CheckPrerequisite {
+	return nil
+}
+
+// Run implements Check.
+func (m *Modules) Run(change scm.Change, options *Options) (err error) {
+	modules := changedModules(change)
+	if len(modules) == 0 {
+		return nil
+	}
+
+	tmpDir, err2 := ioutil.TempDir("", "pre-commit-go")
+	if err2 != nil {
+		return err2
+	}
+	defer func() {
+		err2 := internal.RemoveAll(tmpDir)
+		if err == nil {
+			err = err2
+		}
+	}()
+	root, _, err := exportTree(change, scm.Current, tmpDir)
+	if err != nil {
+		return err
+	}
+	var findings []finding
+	for _, mod := range modules {
+		f, err := m.runModule(options, root, mod)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, f...)
+	}
+	return newFindingsError("go modules are not up to date:", sortFindings(findings))
+}
+
+// runModule verifies the module in directory mod, relative to root.
+//
+// The vendor directory is verified first since 'go mod tidy' modifies go.mod
+// in place.
+func (m *Modules) runModule(options *Options, root, mod string) ([]finding, error) {
+	dir := filepath.Join(root, mod)
+	rel := func(p string) string {
+		return path.Join(filepath.ToSlash(mod), p)
+	}
+	var findings []finding
+	if _, err := os.Stat(filepath.Join(dir, "vendor", "modules.txt")); err == nil {
+		const out = ".pre-commit-go-vendor"
+		if _, err := m.gomod(options, dir, "vendor", "-o", out); err != nil {
+			return nil, err
+		}
+		stale, err := diffDirs(filepath.Join(dir, "vendor"), filepath.Join(dir, out))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range stale {
+			f.path = rel("vendor/" + f.path)
+			f.message += ", please run: go mod vendor"
+			findings = append(findings, f)
+		}
+	}
+
+	if !m.SkipVerify {
+		out, exitCode, _, err := options.captureEnv(dir, modulesEnv, "go", "mod", "verify")
+		if err != nil {
+			return nil, err
+		}
+		if exitCode != 0 {
+			for _, l := range strings.Split(strings.TrimSpace(out), "\n") {
+				if l != "" {
+					findings = append(findings, finding{path: rel("go.sum"), message: "go mod verify: " + l})
+				}
+			}
+		}
+	}
+
+	oldMod, _ := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	oldSum, _ := ioutil.ReadFile(filepath.Join(dir, "go.sum"))
+	if _, err := m.gomod(options, dir, "tidy"); err != nil {
+		return nil, err
+	}
+	newMod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	newSum, _ := ioutil.ReadFile(filepath.Join(dir, "go.sum"))
+	if !bytes.Equal(oldMod, newMod) {
+		findings = append(findings, finding{path: rel("go.mod"), message: "is not tidy, please run: go mod tidy"})
+	}
+	if !bytes.Equal(oldSum, newSum) {
+		entries := map[string]bool{}
+		for _, l := range strings.Split(string(oldSum), "\n") {
+			entries[l] = true
+		}
+		missing := false
+		for _, l := range strings.Split(string(newSum), "\n") {
+			if l != "" && !entries[l] {
+				findings = append(findings, finding{path: rel("go.sum"), message: fmt.Sprintf("is missing %q", l)})
+				missing = true
+			}
+		}
+		if !missing {
+			findings = append(findings, finding{path: rel("go.sum"), message: "is not tidy, please run: go mod tidy"})
+		}
+	}
+	return findings, nil
+}
+
+// gomod runs a go mod command in dir.
+func (m *Modules) gomod(options *Options, dir string, args ...string) (string, error) {
+	args = append([]string{"go", "mod"}, args...)
+	out, exitCode, _, err := options.captureEnv(dir, modulesEnv, args...)
+	if exitCode != 0 || err != nil {
+		return out, fmt.Errorf("%s failed: %s\n%s", strings.Join(args, " "), err, out)
+	}
+	return out, nil
+}
+
+// Private stuff.
+
+// modulesEnv forces module mode independently of the user's environment.
+//
+// GOFLAGS is cleared since e.g. -mod=vendor is rejected by some go mod
+// commands and the workspace is ignored since go.work is usually not
+// committed.
+var modulesEnv = []string{"GO111MODULE=on", "GOFLAGS=", "GOWORK=off"}
+
+// changedModules returns the directories of the modules containing modified
+// files, relative to the repository root.
+//
+// A file belongs to the innermost module containing it.
+func changedModules(change scm.Change) []string {
+	var all []string
+	for _, f := range change.All().Files() {
+		if filepath.Base(f) == "go.mod" {
+			all = append(all, filepath.Dir(f))
+		}
+	}
+	// Longest first so the innermost module is found first.
+	sort.Slice(all, func(i, j int) bool { return len(all[i]) > len(all[j]) })
+	found := map[string]bool{}
+	for _, f := range change.Changed().Files() {
+		for _, mod := range all {
+			if mod == "." || strings.HasPrefix(f, mod+string(filepath.Separator)) {
+				found[mod] = true
+				break
+			}
+		}
+	}
+	out := make([]string, 0, len(found))
+	for mod := range found {
+		out = append(out, mod)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// diffDirs returns the files that differ between the directories old and
+// new, relative to them.
+func diffDirs(old, new string) ([]finding, error) {
+	list := func(root string) (map[string]string, error) {
+		files := map[string]string{}
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			files[filepath.ToSlash(rel)] = p
+			return err
+		})
+		return files, err
+	}
+	oldFiles, err := list(old)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := list(new)
+	if err != nil {
+		return nil, err
+	}
+	var out []finding
+	for rel, p := range newFiles {
+		o, ok := oldFiles[rel]
+		if !ok {
+			out = append(out, finding{path: rel, message: "is missing"})
+			continue
+		}
+		a, err := ioutil.ReadFile(o)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(a, b) {
+			out = append(out, finding{path: rel, message: "is stale"})
+		}
+	}
+	for rel := range oldFiles {
+		if _, ok := newFiles[rel]; !ok {
+			out = append(out, finding{path: rel, message: "is not produced by go mod vendor"})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestModules(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.SkipNow()
+	}
+	const modules = "# example.com/bar v0.0.0 => ./bar\n## explicit; go 1.20\nexample.com/bar\n# example.com/bar => ./bar\n"
+	data := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{
+			map[string]string{},
+			"",
+		},
+		{
+			map[string]string{
+				"vendor/modules.txt":            modules,
+				"vendor/example.com/bar/bar.go": "package bar\n\nconst B = 1\n",
+			},
+			"",
+		},
+		{
+			map[string]string{
+				"go.mod": "module example.com/foo\n\ngo 1.20\n\nrequire (\n\texample.com/bar v0.0.0\n\texample.com/baz v0.0.0\n)\n\nreplace example.com/bar => ./bar\n\nreplace example.com/baz => ./bar\n",
+			},
+			"go modules are not up to date:\ngo.mod: is not tidy, please run: go mod tidy",
+		},
+		{
+			map[string]string{
+				"vendor/modules.txt":            modules,
+				"vendor/example.com/bar/bar.go": "package bar\n",
+				"vendor/example.com/bar/old.go": "package bar\n",
+			},
+			"go modules are not up to date:\n" +
+				"vendor/example.com/bar/bar.go: is stale, please run: go mod vendor\n" +
+				"vendor/example.com/bar/old.go: is not produced by go mod vendor, please run: go mod vendor",
+		},
+	}
+	for i, line := range data {
+		td, err := ioutil.TempDir("", "pre-commit-go")
+		ut.AssertEqual(t, nil, err)
+		files := map[string]string{
+			"go.mod":     "module example.com/foo\n\ngo 1.20\n\nrequire example.com/bar v0.0.0\n\nreplace example.com/bar => ./bar\n",
+			"foo.go":     "package foo\n\nimport \"example.com/bar\"\n\nconst F = bar.B\n",
+			"bar/go.mod": "module example.com/bar\n\ngo 1.20\n",
+			"bar/bar.go": "package bar\n\nconst B = 1\n",
+		}
+		for k, v := range line.files {
+			files[k] = v
+		}
+		change := setup(t, td, files)
+		err = (&Modules{}).Run(change, &Options{})
+		if line.expected == "" {
+			ut.AssertEqualIndex(t, i, nil, err)
+		} else {
+			ut.AssertEqualIndex(t, i, line.expected, err.Error())
+		}
+		ut.AssertEqual(t, nil, internal.RemoveAll(td))
+	}
+}
+
+func TestChangedModules(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go":     "package foo\n",
+		"bar/go.mod": "module example.com/bar\n",
+		"bar/bar.go": "package bar\n",
+	})
+	// The root directory is not a module.
+	ut.AssertEqual(t, []string{"bar"}, changedModules(change))
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer is notified of the progress of a run, e.g. to render it or to
+// record timings.
+//
+// It is set in Options.Observer. Its methods are called concurrently from the
+// goroutines running the checks and must not block.
+type Observer interface {
+	// CheckStarted is called before running a check.
+	CheckStarted(check Check)
+	// CheckFinished is called after running a check, with its error after
+	// applying the suppressions and the baseline.
+	CheckFinished(check Check, duration time.Duration, err error)
+	// CheckSkipped is called instead of CheckStarted when a check is not run,
+	// e.g. because one of its dependencies failed, or instead of CheckFinished
+	// when it was canceled while running. reason is a short explanation.
+	CheckSkipped(check Check, reason string)
+	// ProcessStarted is called before a check starts a process. id identifies
+	// the process in ProcessFinished. check is nil if the Options were not
+	// returned by Options.ForCheck().
+	ProcessStarted(check Check, id int, args []string)
+	// ProcessFinished is called after a process exited. exitCode is -1 if the
+	// process couldn't be started.
+	ProcessFinished(check Check, id int, exitCode int, duration time.Duration)
+	// PrereqInstalling is called before installing the missing prerequisites,
+	// identified by their CheckPrerequisite.URL.
+	PrereqInstalling(urls []string)
+}
+
+// ForCheck returns a copy of the options to pass to check.Run() so the
+// processes it starts are attributed to it in the Observer notifications.
+func (o *Options) ForCheck(check Check) *Options {
+	out := *o
+	out.check = check
+	return &out
+}
+
+// Private stuff.
+
+// lastProcessID is the id of the last process started, see
+// Observer.ProcessStarted.
+var lastProcessID int32
+
+// startProcess notifies the observer that a process is started and returns
+// the function to call once it exited.
+func (o *Options) startProcess(args []string) func(exitCode int, duration time.Duration) {
+	if o.Observer == nil {
+		return func(int, time.Duration) {}
+	}
+	id := int(atomic.AddInt32(&lastProcessID, 1))
+	o.Observer.ProcessStarted(o.check, id, args)
+	return func(exitCode int, duration time.Duration) {
+		o.Observer.ProcessFinished(o.check, id, exitCode, duration)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// DirSettings is the settings overriding the checks of a mode for a
+// directory and its subdirectories, see Settings.PerDir.
+type DirSettings struct {
+	// Checks replaces the checks of the mode of the same check types for the
+	// subtree. An empty list disables the check type in the subtree.
+	Checks Checks `yaml:"checks"`
+}
+
+// Private stuff.
+
+// scopedCheck runs a check only on the files and packages of a subtree.
+type scopedCheck struct {
+	Check
+	// dir is the root of the subtree in POSIX format, "." being the
+	// repository root.
+	dir string
+	// excluded are the subdirectories of dir overridden by per_dir.
+	excluded []string
+}
+
+// GetDescription implements Check.
+func (s *scopedCheck) GetDescription() string {
+	if s.dir == "." {
+		return s.Check.GetDescription()
+	}
+	return s.Check.GetDescription() + " (in " + s.dir + ")"
+}
+
+// Run implements Check.
+func (s *scopedCheck) Run(change scm.Change, options *Options) error {
+	return s.Check.Run(scm.Subset(change, s.contains), options)
+}
+
+// contains returns true if dir, in POSIX format, is in the subtree.
+func (s *scopedCheck) contains(dir string) bool {
+	if !inDir(dir, s.dir) {
+		return false
+	}
+	for _, e := range s.excluded {
+		if inDir(dir, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// unwrap returns the check run by c.
+func unwrap(c Check) Check {
+	if s, ok := c.(*scopedCheck); ok {
+		return s.Check
+	}
+	return c
+}
+
+// inDir returns true if dir is root or one of its subdirectories.
+func inDir(dir, root string) bool {
+	return root == "." || dir == root || strings.HasPrefix(dir, root+"/")
+}
+
+// scopedChecks returns the checks of the settings, with the checks of a type
+// overridden in per_dir restricted to their subtree.
+func (s *Settings) scopedChecks() []Check {
+	var out []Check
+	if len(s.PerDir) == 0 {
+		for _, checks := range s.Checks {
+			out = append(out, checks...)
+		}
+		return out
+	}
+	dirs := make([]string, 0, len(s.PerDir))
+	for dir := range s.PerDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	// overridden returns the directories overriding checkType under root.
+	overridden := func(checkType, root string) []string {
+		var o []string
+		for _, dir := range dirs {
+			if _, ok := s.PerDir[dir].Checks[checkType]; ok && dir != root && inDir(dir, root) {
+				o = append(o, dir)
+			}
+		}
+		return o
+	}
+	add := func(checks []Check, dir string, excluded []string) {
+		for _, c := range checks {
+			if dir == "." && len(excluded) == 0 {
+				out = append(out, c)
+			} else {
+				out = append(out, &scopedCheck{c, dir, excluded})
+			}
+		}
+	}
+	for checkType, checks := range s.Checks {
+		excluded := overridden(checkType, ".")
+		if _, ok := s.PerDir["."].Checks[checkType]; ok {
+			// The whole tree is overridden.
+			continue
+		}
+		add(checks, ".", excluded)
+	}
+	for _, dir := range dirs {
+		for checkType, checks := range s.PerDir[dir].Checks {
+			add(checks, dir, overridden(checkType, dir))
+		}
+	}
+	return out
+}
+
+// checkPerDir returns an error if a per_dir directory is not a clean relative
+// POSIX path.
+func (c *Config) checkPerDir() error {
+	for _, mode := range c.KnownModes() {
+		for dir := range c.Modes[mode].PerDir {
+			if dir == "" || path.Clean(dir) != dir || path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") || strings.Contains(dir, "\\") {
+				return fmt.Errorf("mode \"%s\": invalid per_dir directory \"%s\"", mode, dir)
+			}
+		}
+	}
+	return nil
+}
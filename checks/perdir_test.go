@@ -0,0 +1,121 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPerDir(t *testing.T) {
+	t.Parallel()
+	data := `modes:
+  lint:
+    checks:
+      golint:
+      - blacklist: []
+      gofmt:
+      - {}
+    per_dir:
+      pkg/api:
+        checks:
+          golint:
+          - blacklist: [a]
+      pkg/api/internal:
+        checks:
+          golint: []
+      tools:
+        checks:
+          gofmt: []
+          copyright:
+          - header: "// Copyright"
+`
+	config := &Config{}
+	ut.AssertEqual(t, nil, yaml.Unmarshal([]byte(data), config))
+	enabled, _ := config.EnabledChecks([]Mode{Lint})
+	var actual []string
+	for _, c := range enabled {
+		s := c.GetName() + " ."
+		if sc, ok := c.(*scopedCheck); ok {
+			s = sc.GetName() + " " + sc.dir
+			for _, e := range sc.excluded {
+				s += " -" + e
+			}
+		}
+		actual = append(actual, s)
+	}
+	sort.Strings(actual)
+	expected := []string{
+		"copyright tools",
+		"gofmt . -tools",
+		"golint . -pkg/api -pkg/api/internal",
+		"golint pkg/api -pkg/api/internal",
+	}
+	ut.AssertEqual(t, expected, actual)
+	for _, c := range enabled {
+		if sc, ok := c.(*scopedCheck); ok && sc.dir == "pkg/api" {
+			ut.AssertEqual(t, []string{"a"}, unwrap(c).(*Golint).Blacklist)
+		}
+	}
+}
+
+func TestPerDirContains(t *testing.T) {
+	t.Parallel()
+	s := &scopedCheck{&Gofmt{}, "pkg/api", []string{"pkg/api/internal"}}
+	data := []struct {
+		dir      string
+		expected bool
+	}{
+		{".", false},
+		{"pkg", false},
+		{"pkg/api", true},
+		{"pkg/api/v1", true},
+		{"pkg/apiv2", false},
+		{"pkg/api/internal", false},
+		{"pkg/api/internal/foo", false},
+	}
+	for i, line := range data {
+		ut.AssertEqualIndex(t, i, line.expected, s.contains(line.dir))
+	}
+	ut.AssertEqual(t, "enforces all .go sources are formatted with 'gofmt -s' (in pkg/api)", s.GetDescription())
+}
+
+func TestPerDirGofmt(t *testing.T) {
+	// gofmt scans the whole checkout but only reports the files of its subtree.
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	files := map[string]string{
+		"foo.go":         "package foo\n\nfunc Foo() int {\nreturn 1\n}\n",
+		"tools/tools.go": "package tools\n\nfunc Bar() int {\nreturn 2\n}\n",
+	}
+	change := setup(t, td, files)
+	header := "these files are improperly formatted, please run: gofmt -w -s .\n"
+	root := &scopedCheck{&Gofmt{}, ".", []string{"tools"}}
+	ut.AssertEqual(t, header+"foo.go", root.Run(change, &Options{MaxDuration: 1}).Error())
+	tools := &scopedCheck{&Gofmt{}, "tools", nil}
+	ut.AssertEqual(t, header+filepath.Join("tools", "tools.go"), tools.Run(change, &Options{MaxDuration: 1}).Error())
+}
+
+func TestPerDirInvalid(t *testing.T) {
+	t.Parallel()
+	for _, dir := range []string{"/abs", "../up", "a/", "a//b", "./a"} {
+		config := &Config{}
+		data := "modes:\n  lint:\n    per_dir:\n      \"" + dir + "\":\n        checks: {}\n"
+		ut.AssertEqual(t, errors.New("mode \"lint\": invalid per_dir directory \""+dir+"\""), yaml.Unmarshal([]byte(data), config))
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package protocol defines the json protocol between pcg and the custom checks
+// that opt in with "protocol: 1", and is a client library to implement them.
+//
+// pcg writes a Request describing the change to the standard input of the
+// command and reads a Response with the findings from its standard output.
+// The standard error is printed when the command exits with a non-zero exit
+// code. The findings are then handled like the ones of the native checks:
+// only the ones in modified files are reported, and they can be suppressed or
+// added to the baseline.
+//
+// A check is implemented with Main:
+//
+//	func main() {
+//		protocol.Main(func(req *protocol.Request) ([]protocol.Finding, error) {
+//			var out []protocol.Finding
+//			for _, f := range req.Changed.GoFiles {
+//				...
+//			}
+//			return out, nil
+//		})
+//	}
+//
+// See samples/sample-pre-commit-go-custom-check for a complete example.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Version is the version of the protocol described in this package.
+//
+// A new version is introduced for incompatible changes. Fields may be added to
+// the messages without changing the version.
+const Version = 1
+
+// Set is a set of files and packages, see scm.Set.
+type Set struct {
+	// Files are the files relative to the repository root, including non Go
+	// files.
+	Files []string `json:"files"`
+	// GoFiles are the Go files relative to the repository root.
+	GoFiles []string `json:"go_files"`
+	// Packages are the packages relative to the repository root, e.g. "./foo".
+	Packages []string `json:"packages"`
+	// TestPackages are the packages with tests.
+	TestPackages []string `json:"test_packages"`
+}
+
+// Request is the description of the change written by pcg to the standard
+// input of the command.
+type Request struct {
+	// Version is the version of the protocol.
+	Version int `json:"version"`
+	// RepoRoot is the absolute path of the repository root. The command is run
+	// from this directory.
+	RepoRoot string `json:"repo_root"`
+	// Package is the Go package of the repository root, if in GOPATH.
+	Package string `json:"package"`
+	// GOPATH is the GOPATH used to run the checks.
+	GOPATH string `json:"gopath"`
+	// Modes are the modes being run, e.g. "pre-commit".
+	Modes []string `json:"modes"`
+	// IgnorePatterns are the glob patterns of the ignored paths. The files in
+	// the sets are already filtered.
+	IgnorePatterns []string `json:"ignore_patterns"`
+	// Changed is the files and packages modified by the change.
+	Changed Set `json:"changed"`
+	// Indirect is Changed plus the packages depending on them.
+	Indirect Set `json:"indirect"`
+	// All is the whole repository.
+	All Set `json:"all"`
+}
+
+// Finding is an issue reported by the check.
+type Finding struct {
+	// Path is the file, relative to the repository root or absolute.
+	Path string `json:"path"`
+	// Line is 0 when the finding is about the whole file.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Rule is the identifier of the rule that reported the finding, if any.
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// Response is written by the command on its standard output.
+type Response struct {
+	// Version is the version of the protocol.
+	Version  int       `json:"version"`
+	Findings []Finding `json:"findings"`
+}
+
+// ReadRequest reads a Request and verifies its version.
+func ReadRequest(r io.Reader) (*Request, error) {
+	req := &Request{}
+	if err := json.NewDecoder(r).Decode(req); err != nil {
+		return nil, fmt.Errorf("invalid request: %s", err)
+	}
+	if req.Version != Version {
+		return nil, fmt.Errorf("unsupported protocol version %d, expected %d", req.Version, Version)
+	}
+	return req, nil
+}
+
+// ReadResponse reads a Response and verifies its version.
+func ReadResponse(r io.Reader) (*Response, error) {
+	resp := &Response{}
+	if err := json.NewDecoder(r).Decode(resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %s", err)
+	}
+	if resp.Version != Version {
+		return nil, fmt.Errorf("unsupported protocol version %d, expected %d", resp.Version, Version)
+	}
+	return resp, nil
+}
+
+// WriteResponse writes a Response with the findings.
+func WriteResponse(w io.Writer, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return json.NewEncoder(w).Encode(&Response{Version: Version, Findings: findings})
+}
+
+// Main reads the Request on stdin, calls check and writes its findings on
+// stdout.
+//
+// On error, it is printed on stderr and the process exits with code 1.
+func Main(check func(req *Request) ([]Finding, error)) {
+	if err := run(os.Stdin, os.Stdout, check); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+// Private stuff.
+
+func run(r io.Reader, w io.Writer, check func(req *Request) ([]Finding, error)) error {
+	req, err := ReadRequest(r)
+	if err != nil {
+		return err
+	}
+	findings, err := check(req)
+	if err != nil {
+		return err
+	}
+	return WriteResponse(w, findings)
+}
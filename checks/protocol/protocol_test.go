@@ -0,0 +1,52 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/maruel/ut"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	in := `{"version":1,"repo_root":"/src","changed":{"go_files":["a.go","b.go"]}}`
+	out := &bytes.Buffer{}
+	check := func(req *Request) ([]Finding, error) {
+		ut.AssertEqual(t, "/src", req.RepoRoot)
+		var findings []Finding
+		for _, f := range req.Changed.GoFiles {
+			findings = append(findings, Finding{Path: f, Line: 1, Message: "bad"})
+		}
+		return findings, nil
+	}
+	ut.AssertEqual(t, nil, run(strings.NewReader(in), out, check))
+	ut.AssertEqual(t, `{"version":1,"findings":[{"path":"a.go","line":1,"message":"bad"},{"path":"b.go","line":1,"message":"bad"}]}`+"\n", out.String())
+
+	resp, err := ReadResponse(out)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, 2, len(resp.Findings))
+
+	out.Reset()
+	none := func(req *Request) ([]Finding, error) { return nil, nil }
+	ut.AssertEqual(t, nil, run(strings.NewReader(in), out, none))
+	ut.AssertEqual(t, `{"version":1,"findings":[]}`+"\n", out.String())
+
+	fail := func(req *Request) ([]Finding, error) { return nil, errors.New("failed") }
+	ut.AssertEqual(t, errors.New("failed"), run(strings.NewReader(in), out, fail))
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+	_, err := ReadRequest(strings.NewReader(`{"version":2}`))
+	ut.AssertEqual(t, "unsupported protocol version 2, expected 1", err.Error())
+	_, err = ReadResponse(strings.NewReader(`{}`))
+	ut.AssertEqual(t, "unsupported protocol version 0, expected 1", err.Error())
+	_, err = ReadResponse(strings.NewReader(`garbage`))
+	ut.AssertEqual(t, true, err != nil)
+}
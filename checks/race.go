@@ -0,0 +1,243 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// raceReport is a "WARNING: DATA RACE" report of the race detector.
+type raceReport struct {
+	// accesses are the two conflicting memory accesses, the current one first.
+	accesses []raceAccess
+	// goroutines are the creation sites of the goroutines involved.
+	goroutines []raceGoroutine
+	// pkgs are the packages where this race was reported.
+	pkgs []string
+}
+
+// raceAccess is one of the conflicting memory accesses.
+type raceAccess struct {
+	// op is the operation as printed, e.g. "Write" or "Previous read".
+	op        string
+	goroutine string
+	frames    []raceFrame
+}
+
+// raceGoroutine is the creation site of a goroutine involved in a race.
+type raceGoroutine struct {
+	id     string
+	frames []raceFrame
+}
+
+// raceFrame is a stack frame in a race report. path is relative to the
+// repository root or "" if outside of it.
+type raceFrame struct {
+	function string
+	file     string
+	line     int
+	path     string
+}
+
+func (f *raceFrame) String() string {
+	if f.path != "" {
+		return fmt.Sprintf("%s:%d %s", f.path, f.line, f.function)
+	}
+	return fmt.Sprintf("%s:%d %s", f.file, f.line, f.function)
+}
+
+// location returns the innermost frame inside the repository, or the
+// innermost frame if none is.
+func (a *raceAccess) location() *raceFrame {
+	for i := range a.frames {
+		if a.frames[i].path != "" {
+			return &a.frames[i]
+		}
+	}
+	if len(a.frames) != 0 {
+		return &a.frames[0]
+	}
+	return &raceFrame{}
+}
+
+// key returns a string identifying the race independently of the memory
+// address, the goroutine ids and the callers outside of the location.
+func (r *raceReport) key() string {
+	parts := make([]string, 0, len(r.accesses))
+	for i := range r.accesses {
+		op := strings.ToLower(strings.TrimPrefix(r.accesses[i].op, "Previous "))
+		l := r.accesses[i].location()
+		parts = append(parts, fmt.Sprintf("%s %s:%d", op, l.file, l.line))
+	}
+	return strings.Join(parts, "|")
+}
+
+// touches returns true if any of the accesses happen in one of the files.
+func (r *raceReport) touches(files map[string]bool) bool {
+	for _, a := range r.accesses {
+		for _, f := range a.frames {
+			if files[f.path] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *raceReport) String() string {
+	out := fmt.Sprintf("DATA RACE in %s:\n", strings.Join(r.pkgs, ", "))
+	for _, a := range r.accesses {
+		out += fmt.Sprintf("  %s by %s:\n%s", a.op, a.goroutine, formatRaceFrames(a.frames))
+	}
+	for _, g := range r.goroutines {
+		out += fmt.Sprintf("  Goroutine %s created at:\n%s", g.id, formatRaceFrames(g.frames))
+	}
+	return out
+}
+
+// formatRaceFrames returns the frames inside the repository, eliding the
+// others. If no frame is inside the repository, the innermost one is printed.
+func formatRaceFrames(frames []raceFrame) string {
+	out := ""
+	elided := 0
+	for i, f := range frames {
+		if f.path == "" && (i != 0 || hasRepoFrame(frames)) {
+			elided++
+			continue
+		}
+		out += "    " + f.String() + "\n"
+	}
+	if elided != 0 {
+		out += fmt.Sprintf("    (%d frames in stdlib or vendor)\n", elided)
+	}
+	return out
+}
+
+func hasRepoFrame(frames []raceFrame) bool {
+	for _, f := range frames {
+		if f.path != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	reRaceAccess  = regexp.MustCompile(`^(.+?) at 0x[0-9a-f]+ by (.+):$`)
+	reRaceCreated = regexp.MustCompile(`^Goroutine (\d+) \(\w+\) created at:$`)
+	reRaceFile    = regexp.MustCompile(`^\s+(.+?):(\d+)(?: \+0x[0-9a-f]+)?$`)
+)
+
+const (
+	raceSeparator = "=================="
+	raceHeader    = "WARNING: DATA RACE"
+)
+
+// parseRaces extracts the race reports from go test output. It returns the
+// reports and the output with the reports removed.
+func parseRaces(change scm.Change, out string) ([]*raceReport, string) {
+	var reports []*raceReport
+	var rest []string
+	lines := strings.Split(out, "\n")
+	for i := 0; i < len(lines); i++ {
+		if lines[i] != raceSeparator || i+1 >= len(lines) || lines[i+1] != raceHeader {
+			if !strings.HasSuffix(lines[i], ": race detected during execution of test") {
+				rest = append(rest, lines[i])
+			}
+			continue
+		}
+		r := &raceReport{}
+		var frames *[]raceFrame
+		for i += 2; i < len(lines) && lines[i] != raceSeparator; i++ {
+			line := lines[i]
+			if m := reRaceAccess.FindStringSubmatch(line); m != nil {
+				r.accesses = append(r.accesses, raceAccess{op: m[1], goroutine: m[2]})
+				frames = &r.accesses[len(r.accesses)-1].frames
+			} else if m := reRaceCreated.FindStringSubmatch(line); m != nil {
+				r.goroutines = append(r.goroutines, raceGoroutine{id: m[1]})
+				frames = &r.goroutines[len(r.goroutines)-1].frames
+			} else if line == "" || !strings.HasPrefix(line, " ") {
+				// Anything else, like "Location is global", is not collected.
+				frames = nil
+			} else if frames != nil {
+				if m := reRaceFile.FindStringSubmatch(line); m != nil && len(*frames) != 0 {
+					f := &(*frames)[len(*frames)-1]
+					f.file = m[1]
+					f.line, _ = strconv.Atoi(m[2])
+					if filepath.IsAbs(f.file) {
+						// Relative paths, like _testmain.go, are generated by go test.
+						f.path = repoRelPath(change, f.file)
+					}
+				} else {
+					*frames = append(*frames, raceFrame{function: strings.TrimSuffix(strings.TrimSpace(line), "()")})
+				}
+			}
+		}
+		reports = append(reports, r)
+	}
+	return reports, strings.Join(rest, "\n")
+}
+
+// dedupeRaces merges identical race reports found in multiple packages. The
+// order of first occurrence is kept.
+func dedupeRaces(reports []*raceReport) []*raceReport {
+	var out []*raceReport
+	seen := map[string]*raceReport{}
+	for _, r := range reports {
+		k := r.key()
+		if s, ok := seen[k]; ok {
+			for _, p := range r.pkgs {
+				if !containsString(s.pkgs, p) {
+					s.pkgs = append(s.pkgs, p)
+				}
+			}
+			continue
+		}
+		seen[k] = r
+		out = append(out, r)
+	}
+	for _, r := range out {
+		sort.Strings(r.pkgs)
+	}
+	return out
+}
+
+// formatRaces returns a report of the races touching the files modified in
+// the change and the number of the ones that don't.
+func formatRaces(change scm.Change, reports []*raceReport) string {
+	files := map[string]bool{}
+	for _, f := range change.Changed().GoFiles() {
+		files[f] = true
+	}
+	out := ""
+	others := 0
+	for _, r := range dedupeRaces(reports) {
+		if !r.touches(files) {
+			others++
+			continue
+		}
+		out += r.String()
+	}
+	if others != 0 {
+		out += fmt.Sprintf("%d other data races not touching the modified files\n", others)
+	}
+	return out
+}
+
+func containsString(l []string, s string) bool {
+	for _, i := range l {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
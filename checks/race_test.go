@@ -0,0 +1,110 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestParseRaces(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"rc.go":      "package foo\n",
+		"rc_test.go": "package foo\n",
+	})
+	root := filepath.Join(td, "src", "foo")
+	race := func(caller string, line int) string {
+		return strings.Join([]string{
+			"==================",
+			"WARNING: DATA RACE",
+			"Write at 0x00c0000182a8 by goroutine 8:",
+			"  foo.Race.func1()",
+			"      " + filepath.Join(root, "rc.go") + ":8 +0x33",
+			"",
+			"Previous read at 0x00c0000182a8 by goroutine 7:",
+			"  foo.Race()",
+			"      " + filepath.Join(root, "rc.go") + ":11 +0x104",
+			"  foo." + caller + "()",
+			"      " + filepath.Join(root, "rc_test.go") + ":" + strings.Repeat("1", line) + " +0x1c",
+			"  testing.tRunner()",
+			"      /usr/local/go/src/testing/testing.go:2193 +0x21c",
+			"",
+			"Goroutine 8 (running) created at:",
+			"  foo.Race()",
+			"      " + filepath.Join(root, "rc.go") + ":7 +0xf9",
+			"",
+			"Goroutine 7 (running) created at:",
+			"  testing.(*T).Run()",
+			"      /usr/local/go/src/testing/testing.go:2258 +0xb12",
+			"  main.main()",
+			"      _testmain.go:48 +0x164",
+			"==================",
+			"--- FAIL: " + caller + " (0.00s)",
+			"    testing.go:1865: race detected during execution of test",
+		}, "\n")
+	}
+	out := race("TestRace", 1) + "\n" + race("TestRace2", 2) + "\nFAIL\n"
+	reports, rest := parseRaces(change, out)
+	ut.AssertEqual(t, "--- FAIL: TestRace (0.00s)\n--- FAIL: TestRace2 (0.00s)\nFAIL\n", rest)
+	ut.AssertEqual(t, 2, len(reports))
+	ut.AssertEqual(t, 2, len(reports[0].accesses))
+	ut.AssertEqual(t, "Previous read", reports[0].accesses[1].op)
+	ut.AssertEqual(t, "goroutine 7", reports[0].accesses[1].goroutine)
+	ut.AssertEqual(t, raceFrame{"foo.Race", filepath.Join(root, "rc.go"), 11, "rc.go"}, reports[0].accesses[1].frames[0])
+	ut.AssertEqual(t, raceFrame{"testing.tRunner", "/usr/local/go/src/testing/testing.go", 2193, ""}, reports[0].accesses[1].frames[2])
+	ut.AssertEqual(t, 2, len(reports[0].goroutines))
+	ut.AssertEqual(t, reports[0].key(), reports[1].key())
+
+	reports[0].pkgs = []string{"./b"}
+	reports[1].pkgs = []string{"./a"}
+	expected := "DATA RACE in ./a, ./b:\n" +
+		"  Write by goroutine 8:\n" +
+		"    rc.go:8 foo.Race.func1\n" +
+		"  Previous read by goroutine 7:\n" +
+		"    rc.go:11 foo.Race\n" +
+		"    rc_test.go:1 foo.TestRace\n" +
+		"    (1 frames in stdlib or vendor)\n" +
+		"  Goroutine 8 created at:\n" +
+		"    rc.go:7 foo.Race\n" +
+		"  Goroutine 7 created at:\n" +
+		"    /usr/local/go/src/testing/testing.go:2258 testing.(*T).Run\n" +
+		"    (1 frames in stdlib or vendor)\n"
+	ut.AssertEqual(t, expected, formatRaces(change, reports))
+}
+
+func TestFormatRacesUnrelated(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{"foo.go": "package foo\n"})
+	reports := []*raceReport{
+		{
+			accesses: []raceAccess{
+				{"Write", "goroutine 1", []raceFrame{{"bar.Bar", "/elsewhere/bar.go", 1, ""}}},
+				{"Previous write", "goroutine 2", []raceFrame{{"bar.Bar", "/elsewhere/bar.go", 2, ""}}},
+			},
+			pkgs: []string{"."},
+		},
+	}
+	ut.AssertEqual(t, "1 other data races not touching the modified files\n", formatRaces(change, reports))
+}
@@ -7,9 +7,13 @@ package checks
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/maruel/panicparse/stack"
+	"github.com/maruel/pre-commit-go/scm"
 )
 
 func calcLengths(buckets stack.Buckets) (int, int) {
@@ -68,3 +72,142 @@ func processStackTrace(data string) string {
 	}
 	return out.String()
 }
+
+// processTestOutput returns a short report of a go test failure.
+//
+// Deadlocks and test timeouts are summarized with processHang(), everything
+// else is processed with processStackTrace().
+func processTestOutput(change scm.Change, data string) string {
+	if report := processHang(change, data); report != "" {
+		return report
+	}
+	return processStackTrace(data)
+}
+
+// hangMarkers are the lines printed by the runtime and the testing package
+// when all the goroutines are stuck.
+var hangMarkers = []string{
+	"fatal error: all goroutines are asleep - deadlock!",
+	"panic: test timed out after ",
+}
+
+// processHang returns a report of the stuck goroutines if data contains a
+// deadlock or a test timeout dump. It returns "" otherwise.
+//
+// Goroutines are bucketed and only the frames inside the repository are
+// printed, with the source around the innermost one. Frames in the stdlib and
+// in ignored paths, like vendor, are elided.
+func processHang(change scm.Change, data string) string {
+	marker := ""
+	for _, m := range hangMarkers {
+		if i := strings.Index(data, m); i != -1 {
+			marker = data[i:]
+			if j := strings.Index(marker, "\n\n"); j != -1 {
+				// Keep "running tests:" that follows a timeout.
+				marker = marker[:j]
+			}
+			break
+		}
+	}
+	if marker == "" {
+		return ""
+	}
+	goroutines, _ := stack.ParseDump(bytes.NewBufferString(sanitizeDump(data)), ioutil.Discard)
+	if len(goroutines) == 0 {
+		return ""
+	}
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "%s\n", strings.TrimRight(marker, "\n"))
+	others := 0
+	for _, bucket := range stack.SortBuckets(stack.Bucketize(goroutines, stack.AnyPointer)) {
+		var calls []repoCall
+		for _, call := range bucket.Signature.Stack.Calls {
+			// Relative paths, like _testmain.go, are generated by go test.
+			if p := repoRelPath(change, call.SourcePath); p != "" && filepath.IsAbs(call.SourcePath) {
+				calls = append(calls, repoCall{p, call})
+			}
+		}
+		if len(calls) == 0 {
+			others += len(bucket.Routines)
+			continue
+		}
+		extra := ""
+		if p := repoRelPath(change, bucket.CreatedBy.SourcePath); p != "" && filepath.IsAbs(bucket.CreatedBy.SourcePath) {
+			extra = fmt.Sprintf(" [Created by %s @ %s:%d]", bucket.CreatedBy.Func.PkgDotName(), p, bucket.CreatedBy.Line)
+		}
+		fmt.Fprintf(out, "\n%d: %s%s\n", len(bucket.Routines), bucket.State, extra)
+		for i, c := range calls {
+			fmt.Fprintf(out, "    %s:%d %s\n", c.path, c.call.Line, c.call.Func.PkgDotName())
+			if i == 0 {
+				out.WriteString(sourceSnippet(change.Content(c.path), c.call.Line, "        "))
+			}
+		}
+		if elided := len(bucket.Signature.Stack.Calls) - len(calls); elided != 0 {
+			fmt.Fprintf(out, "    (%d frames in stdlib or vendor)\n", elided)
+		}
+	}
+	if others != 0 {
+		fmt.Fprintf(out, "\n%d goroutines only in stdlib or vendor\n", others)
+	}
+	return out.String()
+}
+
+// repoCall is a stack frame inside the repository.
+type repoCall struct {
+	path string
+	call stack.Call
+}
+
+// sourceSnippet returns the lines around line, marking line with '>'.
+func sourceSnippet(content []byte, line int, indent string) string {
+	if content == nil || line <= 0 {
+		return ""
+	}
+	lines := strings.Split(string(content), "\n")
+	out := ""
+	width := len(fmt.Sprintf("%d", line+1))
+	for i := line - 1; i <= line+1; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		prefix := "  "
+		if i == line {
+			prefix = "> "
+		}
+		out += fmt.Sprintf("%s%s%*d: %s\n", indent, prefix, width, i, strings.TrimRight(lines[i-1], " \t\r"))
+	}
+	return out
+}
+
+// reDumpFunc matches a function call line in a stack dump.
+var reDumpFunc = regexp.MustCompile(`^(\S.*)\((.*)\)$`)
+
+// reDumpCreatedBy matches the goroutine number recent Go versions append to
+// the "created by" line.
+var reDumpCreatedBy = regexp.MustCompile(`^(created by .+) in goroutine \d+$`)
+
+// sanitizeDump rewrites the stack dump lines that the vendored panicparse
+// can't parse, e.g. the arguments "{0x1234?, 0x5?}" printed by recent Go
+// versions.
+func sanitizeDump(data string) string {
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		if m := reDumpCreatedBy.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1]
+			continue
+		}
+		m := reDumpFunc.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		var args []string
+		for _, a := range strings.Split(m[2], ", ") {
+			a = strings.Trim(a, "{}?")
+			if a != "" {
+				args = append(args, a)
+			}
+		}
+		lines[i] = m[1] + "(" + strings.Join(args, ", ") + ")"
+	}
+	return strings.Join(lines, "\n")
+}
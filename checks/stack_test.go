@@ -0,0 +1,93 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestProcessHang(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"dl.go":      "package dl\n\nimport \"sync\"\n\nfunc Wait(name string, n int) {\n\tvar mu sync.Mutex\n\tmu.Lock()\n\tmu.Lock()\n}\n",
+		"dl_test.go": "package dl\n\nimport \"testing\"\n\nfunc TestWait(t *testing.T) {\n\tWait(\"foo\", 3)\n}\n",
+	})
+	root := filepath.Join(td, "src", "foo")
+	dump := "=== RUN   TestWait\n" +
+		"panic: test timed out after 1s\n" +
+		"\trunning tests:\n" +
+		"\t\tTestWait (1s)\n" +
+		"\n" +
+		"goroutine 8 [running]:\n" +
+		"testing.(*M).startAlarm.func1()\n" +
+		"\t/usr/local/go/src/testing/testing.go:2959 +0x34a\n" +
+		"created by time.goFunc\n" +
+		"\t/usr/local/go/src/time/sleep.go:182 +0x2d\n" +
+		"\n" +
+		"goroutine 6 [sync.Mutex.Lock]:\n" +
+		"internal/sync.runtime_SemacquireMutex(0x48aff2?, 0x10?, 0x457bff?)\n" +
+		"\t/usr/local/go/src/runtime/sema.go:95 +0x25\n" +
+		"sync.(*Mutex).Lock(...)\n" +
+		"\t/usr/local/go/src/sync/mutex.go:46\n" +
+		"foo.Wait({0x2b7bce19a760?, 0x4ed9d3?}, 0x4a24b3?)\n" +
+		"\t" + filepath.Join(root, "dl.go") + ":8 +0x6c\n" +
+		"foo.TestWait(0x2b7bce1f8248?)\n" +
+		"\t" + filepath.Join(root, "dl_test.go") + ":6 +0x25\n" +
+		"testing.tRunner(0x2b7bce1f8248, 0x6d4908)\n" +
+		"\t/usr/local/go/src/testing/testing.go:2193 +0xea\n" +
+		"created by testing.(*T).Run in goroutine 1\n" +
+		"\t/usr/local/go/src/testing/testing.go:2258 +0x4d4\n" +
+		"FAIL\tfoo\t1.006s\n"
+	expected := "panic: test timed out after 1s\n" +
+		"\trunning tests:\n" +
+		"\t\tTestWait (1s)\n" +
+		"\n" +
+		"1: sync.Mutex.Lock\n" +
+		"    dl.go:8 foo.Wait\n" +
+		"          7: \tmu.Lock()\n" +
+		"        > 8: \tmu.Lock()\n" +
+		"          9: }\n" +
+		"    dl_test.go:6 foo.TestWait\n" +
+		"    (3 frames in stdlib or vendor)\n" +
+		"\n" +
+		"1 goroutines only in stdlib or vendor\n"
+	ut.AssertEqual(t, expected, processHang(change, dump))
+	ut.AssertEqual(t, "", processHang(change, "--- FAIL: TestWait\nFAIL\n"))
+	ut.AssertEqual(t, "--- FAIL: TestWait\nFAIL\n", processTestOutput(change, "--- FAIL: TestWait\nFAIL\n"))
+}
+
+func TestSanitizeDump(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine 1 [chan receive]:",
+		"testing.(*T).Run(0x2b7bce1f8008, {0x554bc8?, 0x2b7bce1c2aa0?}, 0x6d4908)",
+		"\t/usr/local/go/src/testing/testing.go:2266 +0x4f2",
+		"sync.(*Mutex).Lock(...)",
+		"main.main()",
+		"created by testing.(*T).Run in goroutine 1",
+	}, "\n")
+	expected := strings.Join([]string{
+		"goroutine 1 [chan receive]:",
+		"testing.(*T).Run(0x2b7bce1f8008, 0x554bc8, 0x2b7bce1c2aa0, 0x6d4908)",
+		"\t/usr/local/go/src/testing/testing.go:2266 +0x4f2",
+		"sync.(*Mutex).Lock(...)",
+		"main.main()",
+		"created by testing.(*T).Run",
+	}, "\n")
+	ut.AssertEqual(t, expected, sanitizeDump(in))
+}
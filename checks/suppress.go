@@ -0,0 +1,274 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Suppressions are the suppression comments found in the modified files.
+//
+// A comment "//pcg:ignore <check> <reason>" suppresses the findings of the
+// check on the line it is on or, when it is on its own line, on the next line
+// of code. A comment "//pcg:ignore-file <check> <reason>" suppresses the
+// findings of the check in the whole file. <check> is the name of a check,
+// e.g. "govet", optionally followed by the rule reported by the check, e.g.
+// "govet:printf" or "staticcheck:SA4006". The reason is required.
+//
+// In the non Go files with an extension supported by Copyright, the line
+// comment of the language replaces "//", e.g. "#pcg:ignore" in a .sh file.
+//
+// It is safe to use concurrently.
+type Suppressions struct {
+	lock  sync.Mutex
+	items []*suppression
+	// ran is the checks that ran; false if one of their runs failed without
+	// reporting findings, so it is unknown if their suppressions are used.
+	ran map[string]bool
+}
+
+// LoadSuppressions parses the suppression comments in the modified files.
+//
+// It returns an error listing the malformed comments, e.g. without a reason.
+func LoadSuppressions(change scm.Change) (*Suppressions, error) {
+	s := &Suppressions{ran: map[string]bool{}}
+	var errs []string
+	for _, f := range change.Changed().Files() {
+		marker, ok := commentMarkers[filepath.Ext(f)]
+		if !ok || change.IsIgnored(f) {
+			continue
+		}
+		var items []*suppression
+		var e []string
+		if strings.HasSuffix(f, ".go") {
+			items, e = parseSuppressions(filepath.ToSlash(f), change.Content(f))
+		} else {
+			items, e = parseLineSuppressions(filepath.ToSlash(f), marker, change.Content(f))
+		}
+		s.items = append(s.items, items...)
+		errs = append(errs, e...)
+	}
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("invalid suppressions:\n%s", strings.Join(errs, "\n"))
+	}
+	return s, nil
+}
+
+// Filter removes from err the findings of the check that are suppressed. err
+// is the value returned by the check's Run(). It returns nil when all the
+// findings are suppressed.
+//
+// Errors that do not contain findings, like a test failure, are returned as
+// is.
+func (s *Suppressions) Filter(check string, err error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fe, ok := err.(*findingsError)
+	if !ok {
+		if ran, ok := s.ran[check]; !ok || ran {
+			s.ran[check] = err == nil
+		}
+		return err
+	}
+	if _, ok := s.ran[check]; !ok {
+		s.ran[check] = true
+	}
+	var kept []finding
+	for _, f := range fe.findings {
+		suppressed := false
+		for _, item := range s.items {
+			if item.matches(check, &f) {
+				item.used = true
+				suppressed = true
+			}
+		}
+		if !suppressed {
+			kept = append(kept, f)
+		}
+	}
+	return newFindingsError(fe.header, kept)
+}
+
+// Unused returns the suppressions that didn't match any finding of a check
+// that ran, formatted as strings.
+func (s *Suppressions) Unused() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var out []string
+	for _, item := range s.items {
+		if !item.used && s.ran[item.check] {
+			out = append(out, fmt.Sprintf("%s:%d: unused suppression %s", item.path, item.line, item.comment))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Private stuff.
+
+// suppression is one "//pcg:ignore" comment.
+type suppression struct {
+	path string
+	// line is the line of the comment.
+	line int
+	// target is the line of code the suppression applies to. It is 0 for
+	// file-level suppressions.
+	target  int
+	check   string
+	rule    string
+	comment string
+	used    bool
+}
+
+// matches returns true if the suppression applies to the finding of check.
+func (s *suppression) matches(check string, f *finding) bool {
+	if s.check != check || s.path != f.path || (s.rule != "" && s.rule != f.rule) {
+		return false
+	}
+	return s.target == 0 || s.target == f.line
+}
+
+// reSuppression matches "pcg:ignore <check>[:<rule>] <reason>" and the
+// "-file" form, after the line comment marker.
+var reSuppression = regexp.MustCompile(`^pcg:ignore(-file)?(?:\s+([^\s:]+)(?::(\S+))?)?(?:\s+(.*))?$`)
+
+// parseSuppression parses a suppression comment at line, text being the
+// comment after its marker. It returns the malformed comment as an error.
+func parseSuppression(path string, line int, marker, text string) (*suppression, string) {
+	m := reSuppression.FindStringSubmatch(text)
+	if m == nil || m[2] == "" || strings.TrimSpace(m[4]) == "" {
+		form := marker + "pcg:ignore"
+		if strings.HasPrefix(text, "pcg:ignore-file") {
+			form += "-file"
+		}
+		return nil, fmt.Sprintf("%s:%d: expected \"%s <check> <reason>\"", path, line, form)
+	}
+	if _, ok := KnownChecks[m[2]]; !ok {
+		return nil, fmt.Sprintf("%s:%d: unknown check %q", path, line, m[2])
+	}
+	spec := m[2]
+	if m[3] != "" {
+		spec += ":" + m[3]
+	}
+	return &suppression{path: path, line: line, check: m[2], rule: m[3], comment: marker + "pcg:ignore" + m[1] + " " + spec}, ""
+}
+
+// parseSuppressions returns the suppressions in a Go source file, and the
+// malformed ones as errors.
+func parseSuppressions(path string, content []byte) ([]*suppression, []string) {
+	if content == nil {
+		return nil, nil
+	}
+	var items []*suppression
+	var errs []string
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, -1, len(content))
+	var s scanner.Scanner
+	s.Init(file, content, nil, scanner.ScanComments)
+	// lastLine is the line where the last token of code ended.
+	lastLine := 0
+	// pending are the suppressions on their own line, waiting for the next
+	// line of code.
+	var pending []*suppression
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		line := fset.Position(pos).Line
+		if tok != token.COMMENT {
+			if tok == token.SEMICOLON && lit == "\n" {
+				// Automatically inserted.
+				continue
+			}
+			for _, p := range pending {
+				p.target = line
+			}
+			pending = nil
+			lastLine = fset.Position(pos + token.Pos(len(lit))).Line
+			continue
+		}
+		if !strings.HasPrefix(lit, "//pcg:ignore") {
+			continue
+		}
+		item, e := parseSuppression(path, line, "//", lit[2:])
+		if item == nil {
+			errs = append(errs, e)
+			continue
+		}
+		items = append(items, item)
+		if strings.HasPrefix(lit, "//pcg:ignore-file") {
+			continue
+		}
+		if lastLine == line {
+			item.target = line
+		} else {
+			pending = append(pending, item)
+		}
+	}
+	for _, p := range pending {
+		// There is no code after it.
+		errs = append(errs, fmt.Sprintf("%s:%d: %s applies to no code", path, p.line, p.comment))
+	}
+	return items, errs
+}
+
+// parseLineSuppressions returns the suppressions in a non Go file whose line
+// comments start with marker, and the malformed ones as errors.
+//
+// Unlike parseSuppressions, the file is not tokenized so a marker in a string
+// is considered a comment.
+func parseLineSuppressions(path, marker string, content []byte) ([]*suppression, []string) {
+	var items []*suppression
+	var errs []string
+	var pending []*suppression
+	for i, l := range strings.Split(string(content), "\n") {
+		line := i + 1
+		l = strings.TrimRight(l, "\r")
+		j := strings.Index(l, marker+"pcg:ignore")
+		code := l
+		if j != -1 {
+			code = l[:j]
+		}
+		code = strings.TrimSpace(code)
+		if code != "" && !strings.HasPrefix(code, marker) {
+			for _, p := range pending {
+				p.target = line
+			}
+			pending = nil
+		}
+		if j == -1 {
+			continue
+		}
+		item, e := parseSuppression(path, line, marker, l[j+len(marker):])
+		if item == nil {
+			errs = append(errs, e)
+			continue
+		}
+		items = append(items, item)
+		if strings.HasPrefix(l[j+len(marker):], "pcg:ignore-file") {
+			continue
+		}
+		if code != "" {
+			item.target = line
+		} else {
+			pending = append(pending, item)
+		}
+	}
+	for _, p := range pending {
+		// There is no code after it.
+		errs = append(errs, fmt.Sprintf("%s:%d: %s applies to no code", path, p.line, p.comment))
+	}
+	return items, errs
+}
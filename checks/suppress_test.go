@@ -0,0 +1,125 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestParseSuppressions(t *testing.T) {
+	t.Parallel()
+	content := "//pcg:ignore-file gofmt generated by hand\n" +
+		"package foo\n" +
+		"\n" +
+		"func Foo() {\n" +
+		"\tx := 1 //pcg:ignore govet:printf the format is intentional\n" +
+		"\t//pcg:ignore staticcheck SA4006 is a false positive\n" +
+		"\t// Some more comment.\n" +
+		"\t_ = x\n" +
+		"\ts := \"//pcg:ignore not a comment\"\n" +
+		"\t_ = s\n" +
+		"}\n"
+	items, errs := parseSuppressions("foo.go", []byte(content))
+	ut.AssertEqual(t, 0, len(errs))
+	expected := []*suppression{
+		{path: "foo.go", line: 1, target: 0, check: "gofmt", comment: "//pcg:ignore-file gofmt"},
+		{path: "foo.go", line: 5, target: 5, check: "govet", rule: "printf", comment: "//pcg:ignore govet:printf"},
+		{path: "foo.go", line: 6, target: 8, check: "staticcheck", comment: "//pcg:ignore staticcheck"},
+	}
+	ut.AssertEqual(t, expected, items)
+}
+
+func TestParseSuppressionsInvalid(t *testing.T) {
+	t.Parallel()
+	content := "package foo\n" +
+		"\n" +
+		"//pcg:ignore govet\n" +
+		"var a = 1\n" +
+		"\n" +
+		"//pcg:ignore-file\n" +
+		"//pcg:ignore foo because\n" +
+		"var b = 1\n" +
+		"\n" +
+		"//pcg:ignore govet nothing follows\n"
+	items, errs := parseSuppressions("foo.go", []byte(content))
+	ut.AssertEqual(t, 1, len(items))
+	expected := []string{
+		"foo.go:3: expected \"//pcg:ignore <check> <reason>\"",
+		"foo.go:6: expected \"//pcg:ignore-file <check> <reason>\"",
+		"foo.go:7: unknown check \"foo\"",
+		"foo.go:10: //pcg:ignore govet applies to no code",
+	}
+	ut.AssertEqual(t, expected, errs)
+}
+
+func TestParseLineSuppressions(t *testing.T) {
+	t.Parallel()
+	content := "#!/bin/sh\n" +
+		"#pcg:ignore-file copyright imported from upstream\n" +
+		"\n" +
+		"#pcg:ignore custom the variable is set by the caller\n" +
+		"# Some more comment.\n" +
+		"echo $FOO\n" +
+		"echo $BAR #pcg:ignore custom:SC2086 word splitting is intended\n" +
+		"#pcg:ignore custom\n" +
+		"#pcg:ignore govet nothing follows\n"
+	items, errs := parseLineSuppressions("foo.sh", "#", []byte(content))
+	expected := []*suppression{
+		{path: "foo.sh", line: 2, target: 0, check: "copyright", comment: "#pcg:ignore-file copyright"},
+		{path: "foo.sh", line: 4, target: 6, check: "custom", comment: "#pcg:ignore custom"},
+		{path: "foo.sh", line: 7, target: 7, check: "custom", rule: "SC2086", comment: "#pcg:ignore custom:SC2086"},
+		{path: "foo.sh", line: 9, target: 0, check: "govet", comment: "#pcg:ignore govet"},
+	}
+	ut.AssertEqual(t, expected, items)
+	expectedErrs := []string{
+		"foo.sh:8: expected \"#pcg:ignore <check> <reason>\"",
+		"foo.sh:9: #pcg:ignore govet applies to no code",
+	}
+	ut.AssertEqual(t, expectedErrs, errs)
+}
+
+func TestSuppressionsFilter(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	change := setup(t, td, map[string]string{
+		"foo.go": "//pcg:ignore-file copyright imported from upstream\n" +
+			"package foo\n" +
+			"\n" +
+			"//pcg:ignore govet:printf intentional\n" +
+			"var a = 1\n" +
+			"\n" +
+			"var b = 2 //pcg:ignore golint unused\n" +
+			"\n" +
+			"var c = 3 //pcg:ignore errcheck unused too\n",
+		"foo.sh": "#pcg:ignore-file copyright imported from upstream\necho\n",
+	})
+	s, err := LoadSuppressions(change)
+	ut.AssertEqual(t, nil, err)
+
+	err = s.Filter("govet", newFindingsError("go vet failed:", []finding{
+		{"foo.go", 5, 1, "printf", "bad format", nil},
+		{"foo.go", 5, 1, "shift", "bad shift", nil},
+	}))
+	ut.AssertEqual(t, "go vet failed:\nfoo.go:5:1: bad shift (shift)", err.Error())
+	ut.AssertEqual(t, nil, s.Filter("copyright", newFindingsError("files have invalid copyright header:", []finding{{path: "foo.go"}, {path: "foo.sh"}})))
+	ut.AssertEqual(t, nil, s.Filter("golint", nil))
+	testErr := errors.New("test failed")
+	ut.AssertEqual(t, testErr, s.Filter("errcheck", testErr))
+
+	// golint ran and passed so its suppression is unused. errcheck failed
+	// without findings so it's unknown if its suppression is used.
+	ut.AssertEqual(t, []string{"foo.go:7: unused suppression //pcg:ignore golint"}, s.Unused())
+}
@@ -6,8 +6,11 @@ package checks
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/maruel/pre-commit-go/scm"
 )
 
 // IsContinuousIntegration returns true if it thinks it's running on a known CI
@@ -22,6 +25,28 @@ func IsContinuousIntegration() bool {
 
 // Globals
 
+// exportTree writes a copy of the tree at commit c under tmpDir. It returns
+// the root of the copy and the GOPATH to use to build it.
+//
+// When the repository is inside $GOPATH, the copy is laid out as
+// tmpDir/src/<package> so that imports of the repository's own packages
+// resolve to the copy.
+func exportTree(change scm.Change, c scm.Commit, tmpDir string) (string, string, error) {
+	root := tmpDir
+	gopath := change.Repo().GOPATH()
+	if pkg := change.Package(); pkg != "" {
+		root = filepath.Join(tmpDir, "src", filepath.FromSlash(pkg))
+		gopath = tmpDir + string(filepath.ListSeparator) + gopath
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", "", err
+	}
+	if err := change.Repo().Export(c, root); err != nil {
+		return "", "", err
+	}
+	return root, gopath, nil
+}
+
 // reverse reverses a string.
 func reverse(s string) string {
 	n := len(s)
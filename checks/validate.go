@@ -0,0 +1,391 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigError is an invalid setting in a configuration file.
+type ConfigError struct {
+	// Line is the line of the setting in the file, starting at 1. It is 0 when
+	// unknown.
+	Line int
+	// Path is the setting, e.g. "modes.lint.checks.custom[0].command". It is
+	// empty for syntax errors.
+	Path string
+	// Message describes the problem.
+	Message string
+}
+
+func (c *ConfigError) Error() string {
+	s := c.Message
+	if c.Path != "" {
+		s = c.Path + ": " + s
+	}
+	if c.Line != 0 {
+		s = fmt.Sprintf("line %d: %s", c.Line, s)
+	}
+	return s
+}
+
+// ValidateConfig verifies strictly the content of a configuration file and
+// returns the problems found, sorted by line: syntax errors, unknown fields,
+// values of the wrong type and invalid values, e.g. a custom check without
+// command or a negative max_duration.
+//
+// The settings that depend on the other configuration files, e.g. the mode
+// extended, are not verified.
+func ValidateConfig(content []byte) []*ConfigError {
+	raw := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return yamlErrors(err)
+	}
+	lines := keyLines(content)
+	var errs []*ConfigError
+	add := func(path, msg string) {
+		errs = append(errs, &ConfigError{lines.find(path), path, msg})
+	}
+	checkFields(raw, reflect.TypeOf(Config{}), "", add)
+	if len(errs) == 0 {
+		// Only decode once the types are known to be right, otherwise the errors
+		// of the checks wouldn't have the right line.
+		c := &Config{}
+		if err := yaml.Unmarshal(content, (*plainConfig)(c)); err != nil {
+			return yamlErrors(err)
+		}
+		c.validate(add)
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
+
+// Private stuff.
+
+// plainConfig has the same fields as Config without UnmarshalYAML.
+type plainConfig Config
+
+// validator is implemented by the checks verifying their settings.
+type validator interface {
+	// validate returns the invalid settings, with ConfigError.Path relative
+	// to the check.
+	validate() []*ConfigError
+}
+
+// validate reports the invalid values of the settings of c, which is a
+// single configuration file.
+func (c *Config) validate(add func(path, msg string)) {
+	for i, p := range c.IgnorePatterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			add(fmt.Sprintf("ignore_patterns[%d]", i), fmt.Sprintf("invalid glob \"%s\": %s", p, err))
+		}
+	}
+	modes := make([]string, 0, len(c.Modes))
+	for mode := range c.Modes {
+		modes = append(modes, string(mode))
+	}
+	sort.Strings(modes)
+	for _, mode := range modes {
+		prefix := "modes." + mode
+		settings := c.Modes[Mode(mode)]
+		if settings.Options.MaxDuration < 0 {
+			add(prefix+".max_duration", "must not be negative")
+		}
+		for name, deps := range settings.Options.DependsOn {
+			if _, ok := KnownChecks[name]; !ok {
+				add(prefix+".depends_on."+name, fmt.Sprintf("unknown check \"%s\"", name))
+			}
+			for i, dep := range deps {
+				if _, ok := KnownChecks[dep]; !ok {
+					add(fmt.Sprintf("%s.depends_on.%s[%d]", prefix, name, i), fmt.Sprintf("unknown check \"%s\"", dep))
+				}
+			}
+		}
+		validateChecks(settings.Checks, prefix+".checks", add)
+		for dir, d := range settings.PerDir {
+			validateChecks(d.Checks, prefix+".per_dir."+dir+".checks", add)
+		}
+	}
+}
+
+// validateChecks reports the invalid settings of the checks.
+func validateChecks(c Checks, prefix string, add func(path, msg string)) {
+	for name, checks := range c {
+		for i, check := range checks {
+			if v, ok := check.(validator); ok {
+				for _, e := range v.validate() {
+					add(fmt.Sprintf("%s.%s[%d].%s", prefix, name, i, e.Path), e.Message)
+				}
+			}
+		}
+	}
+}
+
+// yamlErrors converts the errors of the yaml package into ConfigError,
+// extracting the line from messages like "yaml: line 3: did not find
+// expected key".
+func yamlErrors(err error) []*ConfigError {
+	var out []*ConfigError
+	var msgs []string
+	if t, ok := err.(*yaml.TypeError); ok {
+		msgs = t.Errors
+	} else {
+		msgs = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	for _, msg := range msgs {
+		e := &ConfigError{Message: msg}
+		if m := reYAMLLine.FindStringSubmatch(msg); m != nil {
+			e.Line, _ = strconv.Atoi(m[1])
+			e.Message = m[2]
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// reYAMLLine matches the line in an error message of the yaml package.
+var reYAMLLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// checkFields reports the unknown fields and the values of the wrong type in
+// v, the raw yaml decoded value of a setting of type t.
+func checkFields(v interface{}, t reflect.Type, path string, add func(path, msg string)) {
+	if v == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(Checks{}) {
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			add(path, "expected a dict of check types")
+			return
+		}
+		keys, values := sortedKeys(m)
+		for _, name := range keys {
+			p := joinPath(path, name)
+			factory, ok := KnownChecks[name]
+			if !ok {
+				add(p, fmt.Sprintf("unknown check \"%s\"", name))
+				continue
+			}
+			checkFields(values[name], reflect.SliceOf(reflect.TypeOf(factory())), p, add)
+		}
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			add(path, "expected a dict")
+			return
+		}
+		fields := yamlFields(t)
+		keys, values := sortedKeys(m)
+		for _, name := range keys {
+			ft, ok := fields[name]
+			if !ok {
+				add(joinPath(path, name), "unknown field")
+				continue
+			}
+			checkFields(values[name], ft, joinPath(path, name), add)
+		}
+	case reflect.Map:
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			add(path, "expected a dict")
+			return
+		}
+		keys, values := sortedKeys(m)
+		for _, name := range keys {
+			checkFields(values[name], t.Elem(), joinPath(path, name), add)
+		}
+	case reflect.Slice:
+		l, ok := v.([]interface{})
+		if !ok {
+			add(path, "expected a list")
+			return
+		}
+		for i, item := range l {
+			checkFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), add)
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			add(path, "expected a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.(type) {
+		case int, int64:
+		default:
+			add(path, "expected an integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v.(type) {
+		case int, int64, float64:
+		default:
+			add(path, "expected a number")
+		}
+	case reflect.String:
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			add(path, "expected a string")
+		}
+	}
+}
+
+// yamlFields returns the type of the fields of struct t by their yaml name,
+// following the rules of the yaml package.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	out := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+		parts := strings.Split(f.Tag.Get("yaml"), ",")
+		name := parts[0]
+		if name == "-" {
+			continue
+		}
+		inline := false
+		for _, flag := range parts[1:] {
+			inline = inline || flag == "inline"
+		}
+		if inline {
+			for n, ft := range yamlFields(f.Type) {
+				out[n] = ft
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		out[name] = f.Type
+	}
+	return out
+}
+
+// sortedKeys returns the keys of m as strings, sorted, and the values keyed
+// by these strings.
+func sortedKeys(m map[interface{}]interface{}) ([]string, map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		s := fmt.Sprint(k)
+		keys = append(keys, s)
+		values[s] = v
+	}
+	sort.Strings(keys)
+	return keys, values
+}
+
+// joinPath returns the path of the setting name in the setting path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// lineIndex maps the path of the settings to their line.
+type lineIndex map[string]int
+
+// find returns the line of path, or of its closest parent if path isn't
+// indexed, e.g. in a flow style "{...}" dict.
+func (l lineIndex) find(path string) int {
+	for path != "" {
+		if line, ok := l[path]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i == -1 {
+			break
+		}
+		path = path[:i]
+	}
+	return 0
+}
+
+// keyLines indexes the lines of the keys and the list items of the block
+// style yaml content, the style used by pre-commit-go.yml.
+func keyLines(content []byte) lineIndex {
+	type entry struct {
+		indent int
+		item   bool
+		path   string
+		items  int
+	}
+	out := lineIndex{}
+	var stack []*entry
+	for n, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed[0] == '#' || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		for trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			// A list item, it belongs to the key at the same or a lower indentation.
+			for len(stack) != 0 && (stack[len(stack)-1].indent > indent || (stack[len(stack)-1].indent == indent && stack[len(stack)-1].item)) {
+				stack = stack[:len(stack)-1]
+			}
+			parent := &entry{indent: -1}
+			if len(stack) != 0 {
+				parent = stack[len(stack)-1]
+			}
+			e := &entry{indent: indent, item: true, path: fmt.Sprintf("%s[%d]", parent.path, parent.items)}
+			parent.items++
+			out[e.path] = n + 1
+			stack = append(stack, e)
+			rest := strings.TrimLeft(trimmed[1:], " ")
+			indent += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+		key, ok := yamlKey(trimmed)
+		if !ok {
+			continue
+		}
+		for len(stack) != 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		path := key
+		if len(stack) != 0 {
+			path = joinPath(stack[len(stack)-1].path, key)
+		}
+		out[path] = n + 1
+		stack = append(stack, &entry{indent: indent, path: path})
+	}
+	return out
+}
+
+// yamlKey returns the key of a "key: value" line.
+func yamlKey(s string) (string, bool) {
+	if s == "" || s[0] == '{' || s[0] == '[' {
+		return "", false
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end == -1 || !strings.HasPrefix(s[end+2:], ":") {
+			return "", false
+		}
+		return s[1 : end+1], true
+	}
+	i := strings.Index(s, ": ")
+	if i == -1 {
+		if !strings.HasSuffix(s, ":") {
+			return "", false
+		}
+		i = len(s) - 1
+	}
+	return strings.TrimRight(s[:i], " "), true
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/maruel/ut"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidateConfigDefault(t *testing.T) {
+	t.Parallel()
+	content, err := yaml.Marshal(New("0.1"))
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []*ConfigError(nil), ValidateConfig(content))
+}
+
+func TestValidateConfigFields(t *testing.T) {
+	t.Parallel()
+	data := `min_version: "0.1"
+ignore_patterns: vendor
+modes:
+  lint:
+    checks:
+      gofmt:
+      - {}
+      golintt:
+      - {}
+    fail_fast: yes please
+    max_durration: 10
+`
+	expected := []*ConfigError{
+		{2, "ignore_patterns", "expected a list"},
+		{8, "modes.lint.checks.golintt", "unknown check \"golintt\""},
+		{10, "modes.lint.fail_fast", "expected a boolean"},
+		{11, "modes.lint.max_durration", "unknown field"},
+	}
+	ut.AssertEqual(t, expected, ValidateConfig([]byte(data)))
+}
+
+func TestValidateConfigValues(t *testing.T) {
+	t.Parallel()
+	data := `ignore_patterns:
+- "[a"
+modes:
+  lint:
+    checks:
+      coverage:
+      - global:
+          min_coverage: 80
+          max_coverage: 50
+      custom:
+      - display_name: foo
+        batch_size: -1
+      fuzz:
+      - fuzz_time: -5s
+      - fuzz_time: abc
+      - fuzz_time: 0x
+      - fuzz_time: 100x
+    max_duration: -1
+`
+	expected := []*ConfigError{
+		{2, "ignore_patterns[0]", "invalid glob \"[a\": syntax error in pattern"},
+		{8, "modes.lint.checks.coverage[0].global.min_coverage", "80.0 is greater than max_coverage 50.0"},
+		{11, "modes.lint.checks.custom[0].command", "is required"},
+		{12, "modes.lint.checks.custom[0].batch_size", "must not be negative"},
+		{14, "modes.lint.checks.fuzz[0].fuzz_time", "must be positive"},
+		{15, "modes.lint.checks.fuzz[1].fuzz_time", "time: invalid duration \"abc\""},
+		{16, "modes.lint.checks.fuzz[2].fuzz_time", "invalid number of iterations \"0x\""},
+		{18, "modes.lint.max_duration", "must not be negative"},
+	}
+	ut.AssertEqual(t, expected, ValidateConfig([]byte(data)))
+}
+
+func TestValidateConfigSyntax(t *testing.T) {
+	t.Parallel()
+	expected := []*ConfigError{{2, "", "did not find expected node content"}}
+	ut.AssertEqual(t, expected, ValidateConfig([]byte("modes:\n  lint: {\n")))
+}
+
+func TestConfigErrorError(t *testing.T) {
+	t.Parallel()
+	ut.AssertEqual(t, "line 3: modes.lint: unknown field", (&ConfigError{3, "modes.lint", "unknown field"}).Error())
+	ut.AssertEqual(t, "did not find expected key", (&ConfigError{Message: "did not find expected key"}).Error())
+}
+
+func TestKeyLines(t *testing.T) {
+	t.Parallel()
+	data := `# Comment.
+modes:
+  lint:
+    checks:
+      custom:
+      - display_name: foo
+        command: [a]
+      -   display_name: bar
+    "per_dir":
+      a/b: {checks: {}}
+`
+	lines := keyLines([]byte(data))
+	expected := lineIndex{
+		"modes":                       2,
+		"modes.lint":                  3,
+		"modes.lint.checks":           4,
+		"modes.lint.checks.custom":    5,
+		"modes.lint.checks.custom[0]": 6,
+		"modes.lint.checks.custom[0].display_name": 6,
+		"modes.lint.checks.custom[0].command":      7,
+		"modes.lint.checks.custom[1]":              8,
+		"modes.lint.checks.custom[1].display_name": 8,
+		"modes.lint.per_dir":                       9,
+		"modes.lint.per_dir.a/b":                   10,
+	}
+	ut.AssertEqual(t, expected, lines)
+	ut.AssertEqual(t, 10, lines.find("modes.lint.per_dir.a/b.checks.gofmt"))
+	ut.AssertEqual(t, 0, lines.find("ignore_patterns"))
+}
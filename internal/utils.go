@@ -10,6 +10,7 @@
 package internal
 
 import (
+	"bytes"
 	"errors"
 	"os"
 	"os/exec"
@@ -17,22 +18,63 @@ import (
 	"syscall"
 )
 
+// ErrCanceled is returned when the process was not run or was killed because
+// it was canceled.
+var ErrCanceled = errors.New("canceled")
+
 // Capture runs an executable from a directory returns the output, exit code
 // and error if appropriate. It sets the environment variables specified.
 func Capture(wd string, env []string, args ...string) (string, int, error) {
-	exitCode := -1
-	//log.Printf("Capture(%s, %s, %s)", wd, env, args)
+	return CaptureCancelable(nil, wd, env, args...)
+}
+
+// CaptureCancelable is like Capture but kills the process when cancel is
+// closed, in which case ErrCanceled is returned. cancel can be nil.
+func CaptureCancelable(cancel <-chan struct{}, wd string, env []string, args ...string) (string, int, error) {
+	c, err := command(wd, env, args)
+	if err != nil {
+		return "", -1, err
+	}
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	exitCode, err := exitStatus(c, run(c, cancel))
+	// TODO(maruel): Handle code page on Windows.
+	return out.String(), exitCode, err
+}
+
+// CaptureWithInput is like CaptureCancelable but writes stdin to the process
+// standard input and returns its standard output and standard error
+// separately.
+func CaptureWithInput(cancel <-chan struct{}, wd string, env []string, stdin []byte, args ...string) (string, string, int, error) {
+	c, err := command(wd, env, args)
+	if err != nil {
+		return "", "", -1, err
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdin = bytes.NewReader(stdin)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	exitCode, err := exitStatus(c, run(c, cancel))
+	return stdout.String(), stderr.String(), exitCode, err
+}
+
+// Private stuff.
+
+// command returns the command to run args from directory wd with the
+// environment variables overrides env. An empty value removes the variable.
+func command(wd string, env []string, args []string) (*exec.Cmd, error) {
 	var c *exec.Cmd
 	switch len(args) {
 	case 0:
-		return "", -1, errors.New("no command specified")
+		return nil, errors.New("no command specified")
 	case 1:
 		c = exec.Command(args[0])
 	default:
 		c = exec.Command(args[0], args[1:]...)
 	}
 	if wd == "" {
-		return "", -1, errors.New("wd is required")
+		return nil, errors.New("wd is required")
 	}
 	c.Dir = wd
 	procEnv := map[string]string{}
@@ -55,7 +97,48 @@ func Capture(wd string, env []string, args ...string) (string, int, error) {
 	for k, v := range procEnv {
 		c.Env = append(c.Env, k+"="+v)
 	}
-	out, err := c.CombinedOutput()
+	return c, nil
+}
+
+// run runs the process and kills it if cancel is closed before it exited.
+func run(c *exec.Cmd, cancel <-chan struct{}) error {
+	if cancel == nil {
+		return c.Run()
+	}
+	select {
+	case <-cancel:
+		return ErrCanceled
+	default:
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	killed := make(chan bool)
+	go func() {
+		select {
+		case <-cancel:
+			_ = c.Process.Kill()
+			killed <- true
+		case <-done:
+			killed <- false
+		}
+	}()
+	err := c.Wait()
+	close(done)
+	if <-killed {
+		return ErrCanceled
+	}
+	return err
+}
+
+// exitStatus returns the exit code of the process that ran and err, which is
+// reset when the process ran but exited with a non-zero exit code.
+func exitStatus(c *exec.Cmd, err error) (int, error) {
+	exitCode := -1
+	if err == ErrCanceled {
+		return exitCode, err
+	}
 	if c.ProcessState != nil {
 		if waitStatus, ok := c.ProcessState.Sys().(syscall.WaitStatus); ok {
 			exitCode = waitStatus.ExitStatus()
@@ -64,6 +147,5 @@ func Capture(wd string, env []string, args ...string) (string, int, error) {
 			}
 		}
 	}
-	// TODO(maruel): Handle code page on Windows.
-	return string(out), exitCode, err
+	return exitCode, err
 }
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/maruel/ut"
 )
@@ -59,3 +60,55 @@ func TestCaptureNoWd(t *testing.T) {
 	ut.AssertEqual(t, -1, code)
 	ut.AssertEqual(t, errors.New("wd is required"), err)
 }
+
+func TestCaptureWithInput(t *testing.T) {
+	t.Parallel()
+	wd, err := os.Getwd()
+	ut.AssertEqual(t, nil, err)
+	stdout, stderr, code, err := CaptureWithInput(nil, wd, nil, []byte("hello\n"), "git", "hash-object", "--stdin")
+	ut.AssertEqual(t, "ce013625030ba8dba906f756967f9e9ca394464a\n", stdout)
+	ut.AssertEqual(t, "", stderr)
+	ut.AssertEqual(t, 0, code)
+	ut.AssertEqual(t, nil, err)
+
+	_, stderr, code, err = CaptureWithInput(nil, wd, nil, nil, "go", "invalid")
+	ut.AssertEqual(t, true, stderr != "")
+	ut.AssertEqual(t, 2, code)
+	ut.AssertEqual(t, nil, err)
+}
+
+func TestCaptureCancelable(t *testing.T) {
+	t.Parallel()
+	wd, err := os.Getwd()
+	ut.AssertEqual(t, nil, err)
+	cancel := make(chan struct{})
+	out, code, err := CaptureCancelable(cancel, wd, nil, "go", "version")
+	ut.AssertEqual(t, true, strings.HasPrefix(out, "go version"))
+	ut.AssertEqual(t, 0, code)
+	ut.AssertEqual(t, nil, err)
+
+	close(cancel)
+	out, code, err = CaptureCancelable(cancel, wd, nil, "go", "version")
+	ut.AssertEqual(t, "", out)
+	ut.AssertEqual(t, -1, code)
+	ut.AssertEqual(t, ErrCanceled, err)
+}
+
+func TestCaptureCancelableKill(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available")
+	}
+	wd, err := os.Getwd()
+	ut.AssertEqual(t, nil, err)
+	cancel := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(cancel)
+	}()
+	start := time.Now()
+	_, code, err := CaptureCancelable(cancel, wd, nil, "sleep", "60")
+	ut.AssertEqual(t, true, time.Since(start) < 30*time.Second)
+	ut.AssertEqual(t, -1, code)
+	ut.AssertEqual(t, ErrCanceled, err)
+}
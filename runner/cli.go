@@ -0,0 +1,1130 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+	"gopkg.in/yaml.v2"
+)
+
+// Globals
+
+// Bump when the CLI, configuration file format or behavior change in any
+// significant way. This will make files written by this version backward
+// incompatible, forcing downstream users to update their pre-commit-go
+// version.
+const version = "0.5.0"
+
+const hookContent = `#!/bin/sh
+# AUTOGENERATED BY pcg.
+#
+# For more information, run:
+#   pcg help
+#
+# or visit https://github.com/maruel/pre-commit-go
+
+set -e
+%s run-hook %s
+`
+
+const gitNilCommit = "0000000000000000000000000000000000000000"
+
+const helpModes = "Supported modes (with shortcut names):\n- pre-commit / fast / pc\n- pre-push / slow / pp  (default)\n- continous-integration / full / ci\n- lint\n- all: includes both continuous-integration and lint\n- any mode defined in pre-commit-go.yml"
+
+// http://git-scm.com/docs/githooks#_pre_push
+var rePrePush = regexp.MustCompile("^(.+?) ([0-9a-f]{40}) (.+?) ([0-9a-f]{40})$")
+
+var helpText = template.Must(template.New("help").Parse(`pcg: runs pre-commit checks on Go projects, fast.
+
+Supported commands are:
+  baseline    - records the current findings of all enabled checks in
+                pre-commit-go-baseline.yml so only new findings are reported;
+                'baseline prune' removes the findings that were fixed
+  fix         - applies the automatic fixes of the enabled checks (gofmt,
+                goimports, copyright, analyzers suggested fixes) to the
+                modified files, after printing the diff
+  help        - this page
+  prereq      - installs prerequisites, e.g.: errcheck, golint, goimports,
+                govet, etc as applicable for the enabled checks
+  info        - prints the current configuration used
+  install     - runs 'prereq' then installs the git commit hook as
+                .git/hooks/pre-commit
+  installrun  - runs 'prereq', 'install' then 'run'
+  run         - runs all enabled checks
+  run-hook    - used by hooks (pre-commit, pre-push) exclusively
+  validate    - verifies strictly the configuration files and reports the
+                unknown fields and invalid values with their file and line
+  version     - print the tool version number
+  writeconfig - writes (or rewrite) a pre-commit-go.yml
+
+When executed without command, it does the equivalent of 'installrun'.
+
+Supported flags are:
+{{.Usage}}
+Supported checks:
+  Native checks that only depends on the stdlib:{{range .NativeChecks}}
+    - {{printf "%-*s" $.Max .GetName}} : {{.GetDescription}}{{end}}
+
+  Checks that have prerequisites (which will be automatically installed):{{range .OtherChecks}}
+    - {{printf "%-*s" $.Max .GetName}} : {{.GetDescription}}{{end}}
+
+No check ever modify any file. Only 'baseline', 'fix' and 'writeconfig' write
+files.
+`))
+
+const yamlHeader = `# https://github.com/maruel/pre-commit-go configuration file to run checks
+# automatically on commit, on push and on continuous integration service after
+# a push or on merge of a pull request.
+#
+# See https://godoc.org/github.com/maruel/pre-commit-go/checks for more
+# information.
+
+`
+
+var parsedVersion []int
+
+// Runtime Options.
+type application struct {
+	// name is the binary run by the git hooks.
+	name          string
+	config        *checks.Config
+	maxConcurrent int
+	observer      checks.Observer
+}
+
+// logObserver implements checks.Observer by logging the progress, only
+// visible with -v, and printing the prerequisites being installed.
+type logObserver struct{}
+
+func (logObserver) CheckStarted(check checks.Check) {
+	log.Printf("%s...", check.GetName())
+}
+
+func (logObserver) CheckFinished(check checks.Check, duration time.Duration, err error) {
+	if err != nil {
+		log.Printf("... %s in %1.2fs FAILED\n%s", check.GetName(), duration.Seconds(), err)
+	} else {
+		log.Printf("... %s in %1.2fs", check.GetName(), duration.Seconds())
+	}
+}
+
+func (logObserver) CheckSkipped(check checks.Check, reason string) {
+	log.Printf("... %s skipped: %s", check.GetName(), reason)
+}
+
+func (logObserver) ProcessStarted(check checks.Check, id int, args []string) {
+	if check != nil {
+		log.Printf("%s: %s", check.GetName(), strings.Join(args, " "))
+	}
+}
+
+func (logObserver) ProcessFinished(check checks.Check, id int, exitCode int, duration time.Duration) {
+}
+
+func (logObserver) PrereqInstalling(urls []string) {
+	fmt.Printf("Installing:\n")
+	for _, url := range urls {
+		fmt.Printf("  %s\n", url)
+	}
+}
+
+// Utils.
+
+func init() {
+	var err error
+	parsedVersion, err = parseVersion(version)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// parseVersion converts a "1.2.3" string into []int{1,2,3}.
+func parseVersion(v string) ([]int, error) {
+	out := []int{}
+	for _, i := range strings.Split(v, ".") {
+		v, err := strconv.ParseInt(i, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, int(v))
+	}
+	return out, nil
+}
+
+func (a *application) runChecks(change scm.Change, modes []checks.Mode, prereqReady *sync.WaitGroup) error {
+	enabledChecks, options := a.config.EnabledChecks(modes)
+	log.Printf("mode: %s; %d checks; %d max seconds allowed", modes, len(enabledChecks), options.MaxDuration)
+	if change == nil {
+		log.Printf("no change")
+		return nil
+	}
+	if p, ok := a.observer.(progress); ok {
+		p.start(enabledChecks, time.Duration(options.MaxDuration)*time.Second)
+	}
+	opts := &Options{Observer: a.observer, PrereqReady: prereqReady}
+	result, err := RunChecks(change, a.config, modes, opts)
+	if p, ok := a.observer.(progress); ok {
+		p.stop()
+	}
+	if err != nil {
+		return err
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("%s\n", err)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+	if len(result.Errors) != 0 {
+		return fmt.Errorf("checks failed in %1.2fs", result.Duration.Seconds())
+	}
+	return nil
+}
+
+func (a *application) runPreCommit(repo scm.Repo) error {
+	// First, stash index and work dir, keeping only the to-be-committed changes
+	// in the working directory.
+	// TODO(maruel): When running for an git commit --amend run, use HEAD~1.
+	stashed, err := repo.Stash()
+	if err != nil {
+		return err
+	}
+	// Run the checks.
+	var change scm.Change
+	change, err = repo.Between(scm.Current, scm.Head, a.config.IgnorePatterns)
+	if change != nil {
+		err = a.runChecks(change, []checks.Mode{checks.PreCommit}, &sync.WaitGroup{})
+	}
+	// If stashed is false, everything was in the index so no stashing was needed.
+	if stashed {
+		if err2 := repo.Restore(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+func (a *application) runPrePush(repo scm.Repo) (err error) {
+	previous := scm.Head
+	// Will be "" if the current checkout was detached.
+	previousRef := repo.Ref(scm.Head)
+	curr := previous
+	stashed := false
+	defer func() {
+		if curr != previous {
+			p := previousRef
+			if p == "" {
+				p = string(previous)
+			}
+			if err2 := repo.Checkout(p); err == nil {
+				err = err2
+			}
+		}
+		if stashed {
+			if err2 := repo.Restore(); err == nil {
+				err = err2
+			}
+		}
+	}()
+
+	bio := bufio.NewReader(os.Stdin)
+	line := ""
+	triedToStash := false
+	for {
+		if line, err = bio.ReadString('\n'); err != nil {
+			break
+		}
+		matches := rePrePush.FindStringSubmatch(line[:len(line)-1])
+		if len(matches) != 5 {
+			return fmt.Errorf("unexpected stdin for pre-push: %q", line)
+		}
+		from := scm.Commit(matches[4])
+		to := scm.Commit(matches[2])
+		if to == gitNilCommit {
+			// It's being deleted.
+			continue
+		}
+		if to != curr {
+			// Stash, checkout, run tests.
+			if !triedToStash {
+				// Only try to stash once.
+				triedToStash = true
+				if stashed, err = repo.Stash(); err != nil {
+					return
+				}
+			}
+			curr = to
+			if err = repo.Checkout(string(to)); err != nil {
+				return
+			}
+		}
+		if from == gitNilCommit {
+			from = scm.Initial
+		}
+		change, err := repo.Between(to, from, a.config.IgnorePatterns)
+		if err != nil {
+			return err
+		}
+		if err = a.runChecks(change, []checks.Mode{checks.PrePush}, &sync.WaitGroup{}); err != nil {
+			return err
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// processModes returns the modes selected with -m, including the user defined
+// modes in config.
+func processModes(modeFlag string, config *checks.Config) ([]checks.Mode, error) {
+	if len(modeFlag) == 0 {
+		return nil, nil
+	}
+	var modes []checks.Mode
+	for _, p := range strings.Split(modeFlag, ",") {
+		if len(p) != 0 {
+			switch p {
+			case "all":
+				modes = append(modes, checks.ContinuousIntegration, checks.Lint)
+			case string(checks.PreCommit), "fast", "pc":
+				modes = append(modes, checks.PreCommit)
+			case string(checks.PrePush), "slow", "pp":
+				modes = append(modes, checks.PrePush)
+			case string(checks.ContinuousIntegration), "full", "ci":
+				modes = append(modes, checks.ContinuousIntegration)
+			case string(checks.Lint):
+				modes = append(modes, checks.Lint)
+			default:
+				if _, ok := config.Modes[checks.Mode(p)]; !ok {
+					return nil, fmt.Errorf("invalid mode \"%s\"\n\n%s", p, helpModes)
+				}
+				modes = append(modes, checks.Mode(p))
+			}
+		}
+	}
+	return modes, nil
+}
+
+type sortedChecks []checks.Check
+
+func (s sortedChecks) Len() int           { return len(s) }
+func (s sortedChecks) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortedChecks) Less(i, j int) bool { return s[i].GetName() < s[j].GetName() }
+
+// Commands.
+
+func (a *application) cmdHelp(usage string) error {
+	s := &struct {
+		Usage        string
+		Max          int
+		NativeChecks sortedChecks
+		OtherChecks  sortedChecks
+	}{
+		usage,
+		0,
+		sortedChecks{},
+		sortedChecks{},
+	}
+	for name, factory := range checks.KnownChecks {
+		if v := len(name); v > s.Max {
+			s.Max = v
+		}
+		c := factory()
+		if len(c.GetPrerequisites()) == 0 {
+			s.NativeChecks = append(s.NativeChecks, c)
+		} else {
+			s.OtherChecks = append(s.OtherChecks, c)
+		}
+	}
+	sort.Sort(s.NativeChecks)
+	sort.Sort(s.OtherChecks)
+	return helpText.Execute(os.Stdout, s)
+}
+
+// cmdInfo displays the current configuration used.
+func (a *application) cmdInfo(repo scm.ReadOnlyRepo, modes []checks.Mode, sources *configSources) error {
+	if len(sources.files) == 0 {
+		fmt.Printf("Files: <N/A>\n")
+	} else {
+		fmt.Printf("Files:\n")
+		for _, f := range sources.files {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+	fmt.Printf("Repo: %s\n", repo.Root())
+
+	fmt.Printf("MinVersion: %s (%s)\n", a.config.MinVersion, sources.origin("min_version"))
+	content, err := yaml.Marshal(a.config.IgnorePatterns)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("IgnorePatterns: (%s)\n%s", sources.origin("ignore_patterns"), content)
+	if a.config.MaxConcurrent > 0 {
+		fmt.Printf("MaxConcurrent: %d (%s)\n", a.config.MaxConcurrent, sources.origin("max_concurrent"))
+	}
+
+	if len(modes) == 0 {
+		modes = a.config.KnownModes()
+	}
+	for _, mode := range modes {
+		settings := a.config.ModeSettings(mode)
+		maxLen := 0
+		all := []checks.Checks{settings.Checks}
+		for _, d := range settings.PerDir {
+			all = append(all, d.Checks)
+		}
+		for _, c := range all {
+			for checkType := range c {
+				// The check type is the check name.
+				if l := len(checkType); l > maxLen {
+					maxLen = l
+				}
+			}
+		}
+		fmt.Printf("\n%s:\n", mode)
+		if settings.Extends != "" {
+			fmt.Printf("  %-*s %s (%s)\n", maxLen+1, "Extends:", settings.Extends, sources.origin("modes."+string(mode)+".extends"))
+		}
+		fmt.Printf("  %-*s %d seconds (%s)\n", maxLen+1, "Limit:", settings.Options.MaxDuration, sources.modeOrigin(a.config, mode, "max_duration"))
+		err := printChecks("  ", maxLen, settings.Checks, func(checkType string) string {
+			return sources.modeOrigin(a.config, mode, "checks."+checkType)
+		})
+		if err != nil {
+			return err
+		}
+		dirs := make([]string, 0, len(settings.PerDir))
+		for dir := range settings.PerDir {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			fmt.Printf("  per_dir %s:\n", dir)
+			err := printChecks("    ", maxLen, settings.PerDir[dir].Checks, func(checkType string) string {
+				return sources.modeOrigin(a.config, mode, "per_dir."+dir+".checks."+checkType)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printChecks prints the checks with their options and the layer they came
+// from, as returned by origin.
+func printChecks(indent string, maxLen int, c checks.Checks, origin func(checkType string) string) error {
+	for checkType, checks := range c {
+		if len(checks) == 0 {
+			fmt.Printf("%s%s:%s <disabled> (%s)\n", indent, checkType, strings.Repeat(" ", maxLen-len(checkType)), origin(checkType))
+		}
+		for _, check := range checks {
+			name := check.GetName()
+			fmt.Printf("%s%s:%s %s (%s)\n", indent, name, strings.Repeat(" ", maxLen-len(name)), check.GetDescription(), origin(checkType))
+			content, err := yaml.Marshal(check)
+			if err != nil {
+				return err
+			}
+			options := strings.TrimSpace(string(content))
+			if options == "{}" {
+				// It means there's no options.
+				options = "<no option>"
+			}
+			lines := strings.Join(strings.Split(options, "\n"), "\n"+indent+"  ")
+			fmt.Printf("%s  %s\n", indent, lines)
+		}
+	}
+	return nil
+}
+
+// cmdInstallPrereq installs all the packages needed to run the enabled checks.
+func (a *application) cmdInstallPrereq(repo scm.ReadOnlyRepo, modes []checks.Mode, noUpdate bool) error {
+	var wg sync.WaitGroup
+	enabledChecks, _ := a.config.EnabledChecks(modes)
+	number := 0
+	c := make(chan string, len(enabledChecks))
+	for _, check := range enabledChecks {
+		for _, p := range check.GetPrerequisites() {
+			number++
+			wg.Add(1)
+			go func(prereq checks.CheckPrerequisite) {
+				defer wg.Done()
+				if !prereq.IsPresent() {
+					c <- prereq.URL
+				}
+			}(p)
+		}
+	}
+	wg.Wait()
+	log.Printf("Checked for %d prerequisites", number)
+	loop := true
+	// Use a map to remove duplicates.
+	m := map[string]bool{}
+	for loop {
+		select {
+		case url := <-c:
+			m[url] = true
+		default:
+			loop = false
+		}
+	}
+	urls := make([]string, 0, len(m))
+	for url := range m {
+		urls = append(urls, url)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	sort.Strings(urls)
+	if len(urls) != 0 {
+		if noUpdate {
+			out := "-n is specified but prerequites are missing:\n"
+			for _, url := range urls {
+				out += "  " + url + "\n"
+			}
+			return errors.New(out)
+		}
+		a.observer.PrereqInstalling(urls)
+		out, _, err := internal.Capture(wd, nil, append([]string{"go", "get"}, urls...)...)
+		if len(out) != 0 {
+			return fmt.Errorf("prerequisites installation failed: %s", out)
+		}
+		if err != nil {
+			return fmt.Errorf("prerequisites installation failed: %s", err)
+		}
+	}
+	log.Printf("Prerequisites installation succeeded")
+	return nil
+}
+
+// cmdInstall first calls cmdInstallPrereq() then install the
+// .git/hooks/pre-commit and pre-push hooks.
+//
+// Silently ignore installing the hooks when running under a CI. In
+// particular, circleci.com doesn't create the directory .git/hooks.
+func (a *application) cmdInstall(repo scm.ReadOnlyRepo, modes []checks.Mode, noUpdate bool, prereqReady *sync.WaitGroup) (err error) {
+	errCh := make(chan error, 1)
+	go func() {
+		defer prereqReady.Done()
+		errCh <- a.cmdInstallPrereq(repo, modes, noUpdate)
+	}()
+
+	defer func() {
+		if err2 := <-errCh; err == nil {
+			err = err2
+		}
+	}()
+
+	if checks.IsContinuousIntegration() {
+		log.Printf("Running under CI; not installing hooks")
+		return nil
+	}
+	log.Printf("Installing hooks")
+	hookDir, err2 := repo.HookPath()
+	if err2 != nil {
+		return err2
+	}
+	for _, t := range []string{"pre-commit", "pre-push"} {
+		// Always remove hook first if it exists, in case it's a symlink.
+		p := filepath.Join(hookDir, t)
+		_ = os.Remove(p)
+		if err = ioutil.WriteFile(p, []byte(fmt.Sprintf(hookContent, a.name, t)), 0777); err != nil {
+			return err
+		}
+	}
+	log.Printf("Installation done")
+	return nil
+}
+
+// cmdBaseline runs all the enabled checks on all the files and records their
+// findings in the baseline file.
+//
+// When prune is true, the baseline is only pruned of the findings that are not
+// reported anymore.
+func (a *application) cmdBaseline(repo scm.ReadOnlyRepo, modes []checks.Mode, prune bool) error {
+	change, err := repo.Between(scm.Current, scm.Initial, a.config.IgnorePatterns)
+	if err != nil {
+		return err
+	}
+	baseline, err := checks.LoadBaseline(repo.Root())
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		log.Printf("no change")
+		return nil
+	}
+	suppressions, err := checks.LoadSuppressions(change)
+	if err != nil {
+		return err
+	}
+	// The checks are run like RunChecks does so the baseline records the
+	// findings of a normal run.
+	enabledChecks, options := a.config.EnabledChecks(modes)
+	options.Observer = a.observer
+	log.Printf("mode: %s; %d checks", modes, len(enabledChecks))
+	if p, ok := a.observer.(progress); ok {
+		p.start(enabledChecks, time.Duration(options.MaxDuration)*time.Second)
+	}
+	var wg sync.WaitGroup
+	warnings := make(chan error, len(enabledChecks))
+	for _, c := range enabledChecks {
+		wg.Add(1)
+		go func(check checks.Check) {
+			defer wg.Done()
+			a.observer.CheckStarted(check)
+			duration, err := callRun(check, change, options.ForCheck(check))
+			err = suppressions.Filter(check.GetName(), err)
+			a.observer.CheckFinished(check, duration, err)
+			if prune {
+				// New findings are not added; use 'baseline' for that.
+				if err = baseline.Filter(change, check.GetName(), err); err != nil {
+					warnings <- fmt.Errorf("check %s reports errors not in the baseline:\n%s", check.GetName(), err)
+				}
+			} else if !baseline.Add(change, check.GetName(), err) {
+				warnings <- fmt.Errorf("check %s failed without findings, its entries are kept:\n%s", check.GetName(), err)
+			}
+		}(c)
+	}
+	wg.Wait()
+	if p, ok := a.observer.(progress); ok {
+		p.stop()
+	}
+	close(warnings)
+	for warning := range warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+	if prune {
+		fmt.Printf("%d fixed findings pruned from %s\n", baseline.Prune(), checks.BaselineFile)
+	}
+	fmt.Printf("%d findings in %s\n", baseline.Len(), checks.BaselineFile)
+	return baseline.Save(repo.Root())
+}
+
+// cmdFix applies the automatic fixes of the enabled checks to the modified
+// files, after printing the diff.
+func (a *application) cmdFix(repo scm.Repo, modes []checks.Mode, against string, stage bool) error {
+	var old scm.Commit
+	if against != "" {
+		if old = repo.Eval(against); old == scm.Invalid {
+			return errors.New("invalid commit 'against'")
+		}
+	} else {
+		if old = repo.Eval(string(scm.Upstream)); old == scm.Invalid {
+			return errors.New("no upstream")
+		}
+	}
+	change, err := repo.Between(scm.Current, old, a.config.IgnorePatterns)
+	if err != nil || change == nil {
+		return err
+	}
+	suppressions, err := checks.LoadSuppressions(change)
+	if err != nil {
+		return err
+	}
+	baseline, err := checks.LoadBaseline(repo.Root())
+	if err != nil {
+		return err
+	}
+	enabledChecks, options := a.config.EnabledChecks(modes)
+	fixed, err := checks.Fix(change, options, enabledChecks, func(check string, err error) error {
+		return baseline.Filter(change, check, suppressions.Filter(check, err))
+	})
+	if err != nil {
+		return err
+	}
+	if len(fixed) == 0 {
+		fmt.Printf("nothing to fix\n")
+		return nil
+	}
+	files := make([]string, 0, len(fixed))
+	for f := range fixed {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	diff, err := diffFiles(change, fixed, files)
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	for _, f := range files {
+		p := filepath.Join(repo.Root(), f)
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, fixed[f], fi.Mode()); err != nil {
+			return err
+		}
+	}
+	if stage {
+		return repo.Add(files)
+	}
+	return nil
+}
+
+// diffFiles returns the unified diff between the current content of the files
+// and their new content.
+func diffFiles(change scm.Change, fixed map[string][]byte, files []string) (out string, err error) {
+	tmpDir, err2 := ioutil.TempDir("", "pre-commit-go")
+	if err2 != nil {
+		return "", err2
+	}
+	defer func() {
+		err2 := internal.RemoveAll(tmpDir)
+		if err == nil {
+			err = err2
+		}
+	}()
+	for _, f := range files {
+		for dir, content := range map[string][]byte{"a": change.Content(f), "b": fixed[f]} {
+			p := filepath.Join(tmpDir, dir, f)
+			if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+				return "", err
+			}
+			if err := ioutil.WriteFile(p, content, 0600); err != nil {
+				return "", err
+			}
+		}
+	}
+	// git diff exits with 1 when there are differences.
+	out, exitCode, err := internal.Capture(tmpDir, nil, "git", "diff", "--no-index", "--no-color", "--no-prefix", "a", "b")
+	if exitCode != 1 {
+		return "", fmt.Errorf("git diff failed: %s\n%s", err, out)
+	}
+	return out, nil
+}
+
+// cmdRun runs all the enabled checks.
+func (a *application) cmdRun(repo scm.ReadOnlyRepo, modes []checks.Mode, against string, prereqReady *sync.WaitGroup) error {
+	var old scm.Commit
+	if against != "" {
+		if old = repo.Eval(against); old == scm.Invalid {
+			return errors.New("invalid commit 'against'")
+		}
+	} else {
+		if old = repo.Eval(string(scm.Upstream)); old == scm.Invalid {
+			return errors.New("no upstream")
+		}
+	}
+	change, err := repo.Between(scm.Current, old, a.config.IgnorePatterns)
+	if err != nil {
+		return err
+	}
+	return a.runChecks(change, modes, prereqReady)
+}
+
+// cmdRunHook runs the checks in a git repository.
+//
+// Use a precise "stash, run checks, unstash" to ensure that the check is
+// properly run on the data in the index.
+func (a *application) cmdRunHook(repo scm.Repo, mode string, noUpdate bool) error {
+	switch checks.Mode(mode) {
+	case checks.PreCommit:
+		return a.runPreCommit(repo)
+
+	case checks.PrePush:
+		return a.runPrePush(repo)
+
+	case checks.ContinuousIntegration:
+		// Always runs all tests on CI.
+		change, err := repo.Between(scm.Current, scm.Initial, a.config.IgnorePatterns)
+		if err != nil {
+			return err
+		}
+		mode := []checks.Mode{checks.ContinuousIntegration}
+
+		// This is a special case, some users want reproducible builds and in this
+		// case they do not want any external reference and want to enforce
+		// noUpdate, but many people may not care (yet). So default to fetching but
+		// it can be overriden.
+		var prereqReady sync.WaitGroup
+		errCh := make(chan error, 1)
+		prereqReady.Add(1)
+		go func() {
+			defer prereqReady.Done()
+			errCh <- a.cmdInstallPrereq(repo, mode, noUpdate)
+		}()
+		err = a.runChecks(change, mode, &prereqReady)
+		if err2 := <-errCh; err2 != nil {
+			return err2
+		}
+		return err
+
+	default:
+		return errors.New("unsupported hook type for run-hook")
+	}
+}
+
+// cmdValidate reports the problems found in the configuration files.
+func (a *application) cmdValidate(sources *configSources, configErr error) error {
+	if configErr != nil {
+		return configErr
+	}
+	if len(sources.files) == 0 {
+		fmt.Printf("No configuration file found, the default configuration is used\n")
+		return nil
+	}
+	fmt.Printf("Configuration is valid:\n")
+	for _, f := range sources.files {
+		fmt.Printf("  %s\n", f)
+	}
+	return nil
+}
+
+// cmdWriteConfig writes the configuration file of the repository with
+// min_version set to this version, or the default configuration if there is
+// none.
+//
+// Only the repository layer is written with its include list, never the
+// settings merged from the user profile, the .git directory or the included
+// files.
+func (a *application) cmdWriteConfig(repo scm.ReadOnlyRepo, configPath string) error {
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(repo.Root(), configPath)
+	}
+	return writeConfig(configPath)
+}
+
+// writeConfig rewrites the configuration file at path, or writes the default
+// configuration if it doesn't exist.
+func writeConfig(path string) error {
+	var config interface{} = checks.New(version)
+	if content, err := ioutil.ReadFile(path); err == nil {
+		// Keep the content as is, including the keys not set, so the defaults of
+		// future versions still apply.
+		raw := yaml.MapSlice{}
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		found := false
+		for i := range raw {
+			if raw[i].Key == "min_version" {
+				raw[i].Value = version
+				found = true
+			}
+		}
+		if !found {
+			raw = append(yaml.MapSlice{{Key: "min_version", Value: version}}, raw...)
+		}
+		config = raw
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("internal error when marshaling config: %s", err)
+	}
+	_ = os.Remove(path)
+	return ioutil.WriteFile(path, append([]byte(yamlHeader), content...), 0666)
+}
+
+// mainImpl implements pcg. name is the binary run by the git hooks.
+func mainImpl(name string) error {
+	a := application{name: name, observer: logObserver{}}
+
+	exec, args := os.Args[0], os.Args[1:]
+	var commands, flags []string
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			flags = args[i:]
+			break
+		}
+		commands = append(commands, arg)
+	}
+
+	if len(commands) == 0 {
+		if checks.IsContinuousIntegration() {
+			commands = []string{"run-hook", "continuous-integration"}
+		} else {
+			commands = []string{"installrun"}
+		}
+	}
+
+	fs := flag.NewFlagSet(exec, flag.ExitOnError)
+	fs.Usage = func() {
+		b := &bytes.Buffer{}
+		fs.SetOutput(b)
+		fs.PrintDefaults()
+		_ = a.cmdHelp(b.String())
+	}
+	verboseFlag := fs.Bool("v", checks.IsContinuousIntegration() || os.Getenv("VERBOSE") != "", "enables verbose logging output")
+	allFlag := fs.Bool("a", false, "runs checks as if all files had been modified")
+	againstFlag := fs.String("r", "", "runs checks on files modified since this revision, as evaluated by your scm repo")
+	noUpdateFlag := fs.Bool("n", false, "disallow using go get even if a prerequisite is missing; bail out instead")
+	configPathFlag := fs.String("c", "pre-commit-go.yml", "file name of the config to load")
+	modeFlag := fs.String("m", "", "comma separated list of modes to process; default depends on the command")
+	fs.IntVar(&a.maxConcurrent, "C", 0, "maximum number of concurrent processes")
+	stageFlag := fs.Bool("s", false, "with 'fix', stages the fixed files in the index")
+	if err := fs.Parse(flags); err != nil {
+		return err
+	}
+
+	if *allFlag {
+		if *againstFlag != "" {
+			return errors.New("-a can't be used with -r")
+		}
+		*againstFlag = string(scm.Initial)
+	}
+
+	log.SetFlags(log.Lmicroseconds)
+	if !*verboseFlag {
+		log.SetOutput(ioutil.Discard)
+		a.observer = newProgressObserver(os.Stdout)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	repo, err := scm.GetRepo(cwd, "")
+	if err != nil {
+		return err
+	}
+
+	sources, config, configErr := loadConfig(repo, *configPathFlag)
+	a.config = config
+	log.Printf("config: %s", strings.Join(sources.files, ", "))
+	if configErr != nil {
+		switch commands[0] {
+		case "help", "-help", "-h", "validate", "version":
+			// These commands don't need a valid configuration. writeconfig isn't
+			// one of them since it would overwrite the invalid file.
+			log.Printf("%s", configErr)
+		default:
+			// Checks must never run with a configuration silently different from
+			// the one expected, including from the git hooks.
+			return configErr
+		}
+	}
+	if a.maxConcurrent > 0 {
+		log.Printf("using %d maximum concurrent goroutines", a.maxConcurrent)
+		a.config.MaxConcurrent = a.maxConcurrent
+		sources.origins["max_concurrent"] = "command line"
+	}
+
+	modes, err := processModes(*modeFlag, a.config)
+	if err != nil {
+		return err
+	}
+
+	switch cmd := commands[0]; cmd {
+	case "baseline":
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		prune := false
+		if len(commands) > 1 {
+			if commands[1] != "prune" {
+				return fmt.Errorf("unknown baseline command %q, expected 'prune'", commands[1])
+			}
+			prune = true
+		}
+		if len(modes) == 0 {
+			modes = a.config.KnownModes()
+		}
+		return a.cmdBaseline(repo, modes, prune)
+
+	case "fix", "f":
+		cmd = "fix"
+		if len(modes) == 0 {
+			modes = a.config.KnownModes()
+		}
+		return a.cmdFix(repo, modes, *againstFlag, *stageFlag)
+
+	case "help", "-help", "-h":
+		cmd = "help"
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		if *noUpdateFlag != false {
+			return fmt.Errorf("-n can't be used with %s", cmd)
+		}
+		if *configPathFlag != "pre-commit-go.yml" {
+			return fmt.Errorf("-m can't be used with %s", cmd)
+		}
+		if *modeFlag != "" {
+			return fmt.Errorf("-m can't be used with %s", cmd)
+		}
+		b := &bytes.Buffer{}
+		fs.SetOutput(b)
+		fs.PrintDefaults()
+		return a.cmdHelp(b.String())
+
+	case "info":
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		if *noUpdateFlag != false {
+			return fmt.Errorf("-n can't be used with %s", cmd)
+		}
+		return a.cmdInfo(repo, modes, sources)
+
+	case "install", "i":
+		cmd = "install"
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		if len(modes) == 0 {
+			modes = a.config.KnownModes()
+		}
+		var prereqReady sync.WaitGroup
+		prereqReady.Add(1)
+		return a.cmdInstall(repo, modes, *noUpdateFlag, &prereqReady)
+
+	case "installrun":
+		if len(modes) == 0 {
+			modes = []checks.Mode{checks.PrePush}
+		}
+		// Start running all checks that do not have a prerequisite before
+		// installation is completed.
+		var prereqReady sync.WaitGroup
+		prereqReady.Add(1)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- a.cmdInstall(repo, modes, *noUpdateFlag, &prereqReady)
+		}()
+		err := a.cmdRun(repo, modes, *againstFlag, &prereqReady)
+		if err2 := <-errCh; err2 != nil {
+			return err2
+		}
+		return err
+
+	case "prereq", "p":
+		cmd = "prereq"
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		if len(modes) == 0 {
+			modes = a.config.KnownModes()
+		}
+		return a.cmdInstallPrereq(repo, modes, *noUpdateFlag)
+
+	case "run", "r":
+		cmd = "run"
+		if *noUpdateFlag != false {
+			return fmt.Errorf("-n can't be used with %s", cmd)
+		}
+		if len(modes) == 0 {
+			modes = []checks.Mode{checks.PrePush}
+		}
+		return a.cmdRun(repo, modes, *againstFlag, &sync.WaitGroup{})
+
+	case "run-hook":
+		if modes != nil {
+			return fmt.Errorf("-m can't be used with %s", cmd)
+		}
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+
+		if len(commands) < 2 {
+			return errors.New("run-hook is only meant to be used by hooks")
+		}
+		return a.cmdRunHook(repo, commands[1], *noUpdateFlag)
+
+	case "validate":
+		if modes != nil {
+			return fmt.Errorf("-m can't be used with %s", cmd)
+		}
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		if *noUpdateFlag != false {
+			return fmt.Errorf("-n can't be used with %s", cmd)
+		}
+		return a.cmdValidate(sources, configErr)
+
+	case "version":
+		if modes != nil {
+			return fmt.Errorf("-m can't be used with %s", cmd)
+		}
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		if *noUpdateFlag != false {
+			return fmt.Errorf("-n can't be used with %s", cmd)
+		}
+		fmt.Println(version)
+		return nil
+
+	case "writeconfig", "w":
+		if modes != nil {
+			return fmt.Errorf("-m can't be used with %s", cmd)
+		}
+		if *allFlag != false {
+			return fmt.Errorf("-a can't be used with %s", cmd)
+		}
+		if *againstFlag != "" {
+			return fmt.Errorf("-r can't be used with %s", cmd)
+		}
+		return a.cmdWriteConfig(repo, *configPathFlag)
+
+	default:
+		return fmt.Errorf("unknown command %q, try 'help'", cmd)
+	}
+}
+
+// Main runs the pcg command line tool with the arguments in os.Args and exits
+// the process on failure.
+//
+// It is meant to be called from the main() function of a binary embedding
+// pcg, after registering its own checks with checks.Register(). Only
+// opts.Name is used; opts can be nil.
+func Main(opts *Options) {
+	name := "pcg"
+	if opts != nil && opts.Name != "" {
+		name = opts.Name
+	}
+	if err := mainImpl(name); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
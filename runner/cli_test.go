@@ -0,0 +1,96 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestProcessModes(t *testing.T) {
+	config := &checks.Config{Modes: map[checks.Mode]checks.Settings{"nightly": {Extends: checks.ContinuousIntegration}}}
+	data := []struct {
+		in       string
+		expected []checks.Mode
+		err      error
+	}{
+		{"all", []checks.Mode{checks.ContinuousIntegration, checks.Lint}, nil},
+		{"", nil, nil},
+		{"pc", []checks.Mode{checks.PreCommit}, nil},
+		{"fast", []checks.Mode{checks.PreCommit}, nil},
+		{"pp", []checks.Mode{checks.PrePush}, nil},
+		{"slow", []checks.Mode{checks.PrePush}, nil},
+		{"ci", []checks.Mode{checks.ContinuousIntegration}, nil},
+		{"full", []checks.Mode{checks.ContinuousIntegration}, nil},
+		{"nightly", []checks.Mode{"nightly"}, nil},
+		{"pc,nightly", []checks.Mode{checks.PreCommit, "nightly"}, nil},
+		{"foo", nil, errors.New("invalid mode \"foo\"\n\n" + helpModes)},
+	}
+	for i, line := range data {
+		actual, err := processModes(line.in, config)
+		ut.AssertEqualIndex(t, i, line.expected, actual)
+		ut.AssertEqualIndex(t, i, line.err, err)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	// The default configuration is written when there is no file.
+	p := filepath.Join(td, "pre-commit-go.yml")
+	ut.AssertEqual(t, nil, writeConfig(p))
+	sources, config, err := loadConfig(nil, p)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []string{p}, sources.files)
+	ut.AssertEqual(t, checks.New(version), config)
+
+	// Only the file itself is rewritten, not the files it includes.
+	ut.AssertEqual(t, nil, ioutil.WriteFile(filepath.Join(td, "org.yml"), []byte("ignore_patterns:\n- vendor\n"), 0600))
+	ut.AssertEqual(t, nil, ioutil.WriteFile(p, []byte("include:\n- org.yml\nmin_version: \"0.1\"\nmodes:\n  lint:\n    max_duration: 10\n"), 0600))
+	ut.AssertEqual(t, nil, writeConfig(p))
+	content, err := ioutil.ReadFile(p)
+	ut.AssertEqual(t, nil, err)
+	expected := yamlHeader + "include:\n- org.yml\nmin_version: " + version + "\nmodes:\n  lint:\n    max_duration: 10\n"
+	ut.AssertEqual(t, expected, string(content))
+}
+
+func TestCmdBaseline(t *testing.T) {
+	// The checks are run with the observer like RunChecks does.
+	t.Parallel()
+	change, cleanup := newChange(t)
+	defer cleanup()
+	config := &checks.Config{
+		Modes: map[checks.Mode]checks.Settings{
+			checks.Lint: {
+				Checks:  checks.Checks{"fake": {&fake{name: "pass"}}},
+				Options: checks.Options{MaxDuration: 1},
+			},
+		},
+	}
+	o := &recorder{}
+	a := &application{config: config, observer: o}
+	ut.AssertEqual(t, nil, a.cmdBaseline(change.Repo(), []checks.Mode{checks.Lint}, false))
+	sort.Strings(o.events)
+	expected := []string{
+		"check finished pass",
+		"check started pass",
+		"process finished pass 0",
+		"process started pass go version",
+	}
+	ut.AssertEqual(t, expected, o.events)
+}
@@ -0,0 +1,260 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/pre-commit-go/scm"
+	"gopkg.in/yaml.v2"
+)
+
+// configSources records the files the configuration was loaded from and the
+// layer each setting came from.
+type configSources struct {
+	// files are the files loaded, in increasing order of precedence.
+	files []string
+	// origins maps the dotted key of a setting, e.g.
+	// "modes.pre-commit.max_duration", to the file or the layer that set it.
+	origins map[string]string
+}
+
+// origin returns where the setting key came from.
+func (c *configSources) origin(key string) string {
+	if o, ok := c.origins[key]; ok {
+		return o
+	}
+	return "default"
+}
+
+// modeOrigin returns where the setting key of mode came from, following the
+// modes it extends.
+func (c *configSources) modeOrigin(config *checks.Config, mode checks.Mode, key string) string {
+	for seen := map[checks.Mode]bool{}; !seen[mode]; mode = config.Modes[mode].Extends {
+		seen[mode] = true
+		if o, ok := c.origins["modes."+string(mode)+"."+key]; ok {
+			return o
+		}
+		if config.Modes[mode].Extends == "" {
+			break
+		}
+	}
+	return "default"
+}
+
+// loadConfig loads the on disk configuration or use the default configuration
+// if none is found. See CONFIGURATION.md for the logic.
+//
+// The files found are merged, from the lowest to the highest precedence: the
+// user profile, the repository and the .git directory. Each file is preceded
+// by the files it includes. If path is absolute, only this file is loaded.
+//
+// The files are validated strictly. When a file is invalid, the problems of
+// all the files are returned as a configError along with the default
+// configuration.
+func loadConfig(repo scm.ReadOnlyRepo, path string) (*configSources, *checks.Config, error) {
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = []string{path}
+	} else {
+		if user, err := user.Current(); err == nil && user.HomeDir != "" {
+			if runtime.GOOS == "windows" {
+				// ~/<path>
+				candidates = append(candidates, filepath.Join(user.HomeDir, path))
+			} else {
+				// ~/.config/<path>
+				candidates = append(candidates, filepath.Join(user.HomeDir, ".config", path))
+			}
+		}
+		// <repo root>/<path>
+		candidates = append(candidates, filepath.Join(repo.Root(), path))
+		// <repo root>/.git/<path>
+		if scmDir, err := repo.ScmDir(); err == nil {
+			candidates = append(candidates, filepath.Join(scmDir, path))
+		}
+	}
+
+	sources := &configSources{origins: map[string]string{}}
+	merged := map[interface{}]interface{}{}
+	var problems configError
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		layers, errs := loadLayers(candidate, nil)
+		problems = append(problems, errs...)
+		for _, l := range layers {
+			sources.files = append(sources.files, l.path)
+			mergeLayer(merged, l.raw, "", l.path, sources.origins)
+		}
+	}
+	if len(problems) != 0 {
+		return sources, checks.New(version), problems
+	}
+	if len(sources.files) == 0 {
+		return sources, checks.New(version), nil
+	}
+	// Each file is valid on its own but the settings referring to other modes,
+	// e.g. extends, are only verified once merged.
+	config := &checks.Config{}
+	content, err := yaml.Marshal(merged)
+	if err == nil {
+		err = yaml.Unmarshal(content, config)
+	}
+	if err != nil {
+		return sources, checks.New(version), configError{fmt.Sprintf("%s: %s", strings.Join(sources.files, ", "), err)}
+	}
+	return sources, config, nil
+}
+
+// Private stuff.
+
+// configError lists the problems found in the configuration files, one per
+// item, formatted as "file:line: setting: message".
+type configError []string
+
+func (c configError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(c, "\n  ")
+}
+
+// layer is the content of a configuration file.
+type layer struct {
+	path string
+	raw  map[interface{}]interface{}
+}
+
+// loadLayers returns the layers of the configuration file pathname: the files
+// it includes, recursively, followed by itself. stack has the files including
+// pathname.
+//
+// The problems found in pathname and the files it includes are returned; the
+// layers of the valid files are still returned.
+func loadLayers(pathname string, stack []string) ([]layer, configError) {
+	for _, s := range stack {
+		if s == pathname {
+			return nil, configError{fmt.Sprintf("%s: include cycle: %s -> %s", stack[len(stack)-1], strings.Join(stack, " -> "), pathname)}
+		}
+	}
+	content, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		return nil, configError{err.Error()}
+	}
+	var problems configError
+	for _, e := range checks.ValidateConfig(content) {
+		file := pathname
+		if e.Line != 0 {
+			file = fmt.Sprintf("%s:%d", pathname, e.Line)
+		}
+		e.Line = 0
+		problems = append(problems, fmt.Sprintf("%s: %s", file, e))
+	}
+	raw := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		// It's a syntax error, already reported.
+		return nil, problems
+	}
+	if v, ok := raw["min_version"]; ok {
+		if err := checkMinVersion(fmt.Sprint(v)); err != nil {
+			return nil, append(problems, fmt.Sprintf("%s: %s", pathname, err))
+		}
+	}
+	// The included files are verified even if this one is invalid, so all the
+	// problems are reported at once.
+	var includes []string
+	if v, ok := raw["include"]; ok {
+		delete(raw, "include")
+		items, _ := v.([]interface{})
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok || s == "" {
+				problems = append(problems, fmt.Sprintf("%s: invalid include %v", pathname, item))
+				continue
+			}
+			includes = append(includes, s)
+		}
+	}
+	var out []layer
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(pathname), include)
+		}
+		if _, err := os.Stat(include); os.IsNotExist(err) {
+			problems = append(problems, fmt.Sprintf("%s: include %s not found", pathname, include))
+			continue
+		}
+		layers, errs := loadLayers(include, append(stack, pathname))
+		problems = append(problems, errs...)
+		out = append(out, layers...)
+	}
+	return append(out, layer{pathname, raw}), problems
+}
+
+// checkMinVersion returns an error if this version of pcg is older than
+// minVersion. An invalid version is logged but ignored.
+func checkMinVersion(minVersion string) error {
+	configVersion, err := parseVersion(minVersion)
+	if err != nil {
+		log.Printf("invalid version %s", minVersion)
+	}
+	for i, v := range configVersion {
+		if len(parsedVersion) <= i {
+			if v == 0 {
+				// 3.0 == 3.0.0
+				continue
+			}
+			return fmt.Errorf("requires newer version %s", minVersion)
+		}
+		if parsedVersion[i] > v {
+			break
+		}
+		if parsedVersion[i] < v {
+			return fmt.Errorf("requires newer version %s", minVersion)
+		}
+	}
+	return nil
+}
+
+// mergeLayer merges the settings of src into dst and records in origins that
+// they came from file.
+//
+// Dicts are merged key by key, e.g. the modes, the options of a mode and its
+// checks. Everything else, including the list of checks of a check type and
+// ignore_patterns, replaces the value of the lower layers.
+func mergeLayer(dst, src map[interface{}]interface{}, prefix, file string, origins map[string]string) {
+	keys := make([]string, 0, len(src))
+	byKey := map[string]interface{}{}
+	for k := range src {
+		key := fmt.Sprint(k)
+		keys = append(keys, key)
+		byKey[key] = k
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		k := byKey[key]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if m, ok := src[k].(map[interface{}]interface{}); ok {
+			d, ok := dst[k].(map[interface{}]interface{})
+			if !ok {
+				d = map[interface{}]interface{}{}
+				dst[k] = d
+			}
+			mergeLayer(d, m, key, file, origins)
+			continue
+		}
+		dst[k] = src[k]
+		origins[key] = file
+	}
+}
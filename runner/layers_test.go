@@ -0,0 +1,177 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/ut"
+)
+
+func TestLoadConfigLayers(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	org := filepath.Join(td, "org", "org.yml")
+	repo := filepath.Join(td, "repo", "pre-commit-go.yml")
+	ut.AssertEqual(t, nil, os.MkdirAll(filepath.Dir(org), 0700))
+	ut.AssertEqual(t, nil, os.MkdirAll(filepath.Dir(repo), 0700))
+	ut.AssertEqual(t, nil, ioutil.WriteFile(org, []byte(`ignore_patterns:
+- vendor
+modes:
+  pre-commit:
+    checks:
+      gofmt:
+      - {}
+      copyright:
+      - header: "// Copyright"
+    max_duration: 5
+    fail_fast: true
+`), 0600))
+	ut.AssertEqual(t, nil, ioutil.WriteFile(repo, []byte(`include:
+- ../org/org.yml
+modes:
+  pre-commit:
+    checks:
+      copyright: []
+      test:
+      - extra_args: [-short]
+    max_duration: 10
+`), 0600))
+
+	sources, config, err := loadConfig(nil, repo)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []string{org, repo}, sources.files)
+	ut.AssertEqual(t, []string{"vendor"}, config.IgnorePatterns)
+	settings := config.Modes[checks.PreCommit]
+	ut.AssertEqual(t, 10, settings.Options.MaxDuration)
+	ut.AssertEqual(t, true, settings.Options.FailFast)
+	ut.AssertEqual(t, 1, len(settings.Checks["gofmt"]))
+	ut.AssertEqual(t, 0, len(settings.Checks["copyright"]))
+	ut.AssertEqual(t, 1, len(settings.Checks["test"]))
+
+	ut.AssertEqual(t, org, sources.origin("ignore_patterns"))
+	ut.AssertEqual(t, repo, sources.origin("modes.pre-commit.max_duration"))
+	ut.AssertEqual(t, org, sources.origin("modes.pre-commit.fail_fast"))
+	ut.AssertEqual(t, org, sources.origin("modes.pre-commit.checks.gofmt"))
+	ut.AssertEqual(t, repo, sources.origin("modes.pre-commit.checks.copyright"))
+	ut.AssertEqual(t, "default", sources.origin("min_version"))
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	t.Parallel()
+	sources, config, err := loadConfig(nil, filepath.Join(os.TempDir(), "pre-commit-go-does-not-exist.yml"))
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []string(nil), sources.files)
+	ut.AssertEqual(t, checks.New(version), config)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	org := filepath.Join(td, "org.yml")
+	repo := filepath.Join(td, "pre-commit-go.yml")
+	ut.AssertEqual(t, nil, ioutil.WriteFile(org, []byte(`modes:
+  lint:
+    checks:
+      custom:
+      - display_name: foo
+`), 0600))
+	ut.AssertEqual(t, nil, ioutil.WriteFile(repo, []byte(`include:
+- org.yml
+- missing.yml
+modes:
+  lint:
+    max_durration: 10
+`), 0600))
+
+	sources, config, err := loadConfig(nil, repo)
+	expected := configError{
+		repo + ":6: modes.lint.max_durration: unknown field",
+		org + ":5: modes.lint.checks.custom[0].command: is required",
+		repo + ": include " + filepath.Join(td, "missing.yml") + " not found",
+	}
+	ut.AssertEqual(t, expected, err)
+	ut.AssertEqual(t, []string{org, repo}, sources.files)
+	ut.AssertEqual(t, checks.New(version), config)
+}
+
+func TestLoadConfigInvalidExtends(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	repo := filepath.Join(td, "pre-commit-go.yml")
+	ut.AssertEqual(t, nil, ioutil.WriteFile(repo, []byte("modes:\n  nightly:\n    extends: weekly\n"), 0600))
+	sources, config, err := loadConfig(nil, repo)
+	ut.AssertEqual(t, configError{repo + ": mode \"nightly\" extends unknown mode \"weekly\""}, err)
+	ut.AssertEqual(t, []string{repo}, sources.files)
+	ut.AssertEqual(t, checks.New(version), config)
+}
+
+func TestLoadLayersIncludeCycle(t *testing.T) {
+	t.Parallel()
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	defer func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}()
+	a := filepath.Join(td, "a.yml")
+	b := filepath.Join(td, "b.yml")
+	ut.AssertEqual(t, nil, ioutil.WriteFile(a, []byte("include:\n- b.yml\n"), 0600))
+	ut.AssertEqual(t, nil, ioutil.WriteFile(b, []byte("include:\n- a.yml\n"), 0600))
+	_, errs := loadLayers(a, nil)
+	ut.AssertEqual(t, configError{b + ": include cycle: " + a + " -> " + b + " -> " + a}, errs)
+}
+
+func TestModeOrigin(t *testing.T) {
+	t.Parallel()
+	config := &checks.Config{
+		Modes: map[checks.Mode]checks.Settings{
+			checks.ContinuousIntegration: {},
+			"nightly":                    {Extends: checks.ContinuousIntegration},
+		},
+	}
+	sources := &configSources{
+		origins: map[string]string{
+			"modes.continuous-integration.checks.gofmt": "org.yml",
+			"modes.nightly.checks.test":                 "repo.yml",
+		},
+	}
+	ut.AssertEqual(t, "org.yml", sources.modeOrigin(config, "nightly", "checks.gofmt"))
+	ut.AssertEqual(t, "repo.yml", sources.modeOrigin(config, "nightly", "checks.test"))
+	ut.AssertEqual(t, "default", sources.modeOrigin(config, "nightly", "max_duration"))
+}
+
+func TestCheckMinVersion(t *testing.T) {
+	t.Parallel()
+	ut.AssertEqual(t, nil, checkMinVersion("0.1"))
+	ut.AssertEqual(t, nil, checkMinVersion(version))
+	ut.AssertEqual(t, errors.New("requires newer version 1000.0"), checkMinVersion("1000.0"))
+	ut.AssertEqual(t, nil, checkMinVersion("a.b"))
+}
@@ -0,0 +1,295 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maruel/pre-commit-go/checks"
+)
+
+// progress is implemented by the observers that need to know the checks
+// before they are run.
+type progress interface {
+	// start is called before running the checks.
+	start(enabledChecks []checks.Check, max time.Duration)
+	// stop is called once all the checks finished.
+	stop()
+}
+
+// newProgressObserver returns the observer rendering the progress to w: live
+// if w is a terminal, one line per check otherwise.
+func newProgressObserver(w *os.File) checks.Observer {
+	if isTerminal(w) {
+		return &ttyObserver{w: w, refresh: 100 * time.Millisecond}
+	}
+	return &plainObserver{w: w}
+}
+
+// isTerminal returns true if f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// plainObserver prints a line per check as it finishes.
+type plainObserver struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (p *plainObserver) CheckStarted(check checks.Check) {
+}
+
+func (p *plainObserver) CheckFinished(check checks.Check, duration time.Duration, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	status := "ok  "
+	if err != nil {
+		status = "FAIL"
+	}
+	fmt.Fprintf(p.w, "%s %s in %1.2fs\n", status, check.GetName(), duration.Seconds())
+}
+
+func (p *plainObserver) CheckSkipped(check checks.Check, reason string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fmt.Fprintf(p.w, "skip %s: %s\n", check.GetName(), reason)
+}
+
+func (p *plainObserver) ProcessStarted(check checks.Check, id int, args []string) {
+}
+
+func (p *plainObserver) ProcessFinished(check checks.Check, id int, exitCode int, duration time.Duration) {
+}
+
+func (p *plainObserver) PrereqInstalling(urls []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fmt.Fprintf(p.w, "Installing:\n")
+	for _, url := range urls {
+		fmt.Fprintf(p.w, "  %s\n", url)
+	}
+}
+
+// Check states in ttyObserver.
+const (
+	checkPending = iota
+	checkRunning
+	checkPassed
+	checkFailed
+	checkSkipped
+)
+
+// checkStatus is the status of a check rendered by ttyObserver.
+type checkStatus struct {
+	name      string
+	state     int
+	started   time.Time
+	duration  time.Duration
+	reason    string
+	processes map[int]string
+}
+
+// ttyObserver redraws the status of all the checks on a terminal until they
+// all finished.
+//
+// Each check is shown with a spinner while running, its elapsed time versus
+// the maximum allowed duration and the processes it is running.
+type ttyObserver struct {
+	w       io.Writer
+	refresh time.Duration
+
+	lock       sync.Mutex
+	max        time.Duration
+	statuses   []*checkStatus
+	byCheck    map[checks.Check]*checkStatus
+	installing []string
+	frame      int
+	lines      int
+	running    bool
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+func (t *ttyObserver) start(enabledChecks []checks.Check, max time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.max = max
+	t.statuses = make([]*checkStatus, 0, len(enabledChecks))
+	t.byCheck = make(map[checks.Check]*checkStatus, len(enabledChecks))
+	t.lines = 0
+	t.running = true
+	for _, c := range enabledChecks {
+		s := &checkStatus{name: c.GetName(), processes: map[int]string{}}
+		t.statuses = append(t.statuses, s)
+		t.byCheck[c] = s
+	}
+	t.done = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.lock.Lock()
+				t.frame++
+				t.draw(time.Now())
+				t.lock.Unlock()
+			case <-t.done:
+				return
+			}
+		}
+	}()
+}
+
+func (t *ttyObserver) stop() {
+	close(t.done)
+	t.wg.Wait()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// The prerequisites are installed once the checks are done.
+	t.installing = nil
+	t.draw(time.Now())
+	t.running = false
+}
+
+func (t *ttyObserver) CheckStarted(check checks.Check) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if s := t.byCheck[check]; s != nil {
+		s.state = checkRunning
+		s.started = time.Now()
+	}
+}
+
+func (t *ttyObserver) CheckFinished(check checks.Check, duration time.Duration, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if s := t.byCheck[check]; s != nil {
+		s.state = checkPassed
+		if err != nil {
+			s.state = checkFailed
+		}
+		s.duration = duration
+		s.processes = map[int]string{}
+	}
+}
+
+func (t *ttyObserver) CheckSkipped(check checks.Check, reason string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if s := t.byCheck[check]; s != nil {
+		s.state = checkSkipped
+		s.reason = reason
+		s.processes = map[int]string{}
+	}
+}
+
+func (t *ttyObserver) ProcessStarted(check checks.Check, id int, args []string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if s := t.byCheck[check]; s != nil {
+		s.processes[id] = strings.Join(args, " ")
+	}
+}
+
+func (t *ttyObserver) ProcessFinished(check checks.Check, id int, exitCode int, duration time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if s := t.byCheck[check]; s != nil {
+		delete(s.processes, id)
+	}
+}
+
+func (t *ttyObserver) PrereqInstalling(urls []string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.running {
+		// Not running checks, e.g. 'pcg prereq'.
+		fmt.Fprintf(t.w, "Installing:\n")
+		for _, url := range urls {
+			fmt.Fprintf(t.w, "  %s\n", url)
+		}
+		return
+	}
+	t.installing = urls
+}
+
+// spinner is the animation of the running checks.
+const spinner = `-\|/`
+
+// maxProcessLen is the maximum length of a process command line shown.
+const maxProcessLen = 72
+
+// draw redraws the lines over the ones drawn previously.
+//
+// It must be called with lock held.
+func (t *ttyObserver) draw(now time.Time) {
+	lines := t.render(now)
+	out := ""
+	if t.lines != 0 {
+		out += fmt.Sprintf("\x1b[%dA", t.lines)
+	}
+	for _, l := range lines {
+		out += "\r\x1b[2K" + l + "\n"
+	}
+	// Clear the lines left from the previous draw.
+	out += "\x1b[J"
+	_, _ = io.WriteString(t.w, out)
+	t.lines = len(lines)
+}
+
+// render returns the lines showing the status of the checks at time now.
+//
+// It must be called with lock held.
+func (t *ttyObserver) render(now time.Time) []string {
+	width := 0
+	for _, s := range t.statuses {
+		if len(s.name) > width {
+			width = len(s.name)
+		}
+	}
+	var lines []string
+	if len(t.installing) != 0 {
+		lines = append(lines, "Installing: "+strings.Join(t.installing, " "))
+	}
+	for _, s := range t.statuses {
+		switch s.state {
+		case checkPending:
+			lines = append(lines, fmt.Sprintf("  %-*s pending", width, s.name))
+		case checkRunning:
+			frame := spinner[t.frame%len(spinner)]
+			lines = append(lines, fmt.Sprintf("%c %-*s %1.1fs/%s", frame, width, s.name, now.Sub(s.started).Seconds(), t.max))
+			ids := make([]int, 0, len(s.processes))
+			for id := range s.processes {
+				ids = append(ids, id)
+			}
+			sort.Ints(ids)
+			for _, id := range ids {
+				p := s.processes[id]
+				if len(p) > maxProcessLen {
+					p = p[:maxProcessLen-3] + "..."
+				}
+				lines = append(lines, "    $ "+p)
+			}
+		case checkPassed:
+			lines = append(lines, fmt.Sprintf("  %-*s ok in %1.2fs", width, s.name, s.duration.Seconds()))
+		case checkFailed:
+			lines = append(lines, fmt.Sprintf("  %-*s FAILED in %1.2fs", width, s.name, s.duration.Seconds()))
+		case checkSkipped:
+			lines = append(lines, fmt.Sprintf("  %-*s skipped: %s", width, s.name, s.reason))
+		}
+	}
+	return lines
+}
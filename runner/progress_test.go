@@ -0,0 +1,62 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/ut"
+)
+
+func TestPlainObserver(t *testing.T) {
+	t.Parallel()
+	b := &bytes.Buffer{}
+	p := &plainObserver{w: b}
+	p.CheckFinished(&checks.Gofmt{}, 1500*time.Millisecond, nil)
+	p.CheckFinished(&checks.Test{}, 2*time.Second, errors.New("failed"))
+	p.PrereqInstalling([]string{"example.com/foo"})
+	ut.AssertEqual(t, "ok   gofmt in 1.50s\nFAIL test in 2.00s\nInstalling:\n  example.com/foo\n", b.String())
+}
+
+func TestTTYObserver(t *testing.T) {
+	t.Parallel()
+	b := &bytes.Buffer{}
+	o := &ttyObserver{w: b, refresh: time.Hour}
+	gofmt := &checks.Gofmt{}
+	test := &checks.Test{}
+	copyright := &checks.Copyright{}
+	o.start([]checks.Check{gofmt, test, copyright}, 120*time.Second)
+	o.CheckStarted(gofmt)
+	o.CheckFinished(gofmt, 100*time.Millisecond, nil)
+	o.CheckStarted(test)
+	o.ProcessStarted(test, 1, []string{"go", "test", "./..."})
+	o.ProcessStarted(test, 2, []string{"go", "test", string(make([]byte, 100))})
+	o.ProcessFinished(test, 2, 0, time.Second)
+	o.PrereqInstalling([]string{"example.com/foo"})
+
+	o.lock.Lock()
+	started := o.byCheck[test].started
+	expected := []string{
+		"Installing: example.com/foo",
+		"  gofmt     ok in 0.10s",
+		"- test      1.5s/2m0s",
+		"    $ go test ./...",
+		"  copyright pending",
+	}
+	ut.AssertEqual(t, expected, o.render(started.Add(1500*time.Millisecond)))
+	o.lock.Unlock()
+
+	o.CheckFinished(test, 2*time.Second, errors.New("failed"))
+	o.stop()
+	expectedDraw := "\r\x1b[2K  gofmt     ok in 0.10s\n" +
+		"\r\x1b[2K  test      FAILED in 2.00s\n" +
+		"\r\x1b[2K  copyright pending\n" +
+		"\x1b[J"
+	ut.AssertEqual(t, expectedDraw, b.String())
+}
@@ -0,0 +1,241 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package runner runs the enabled checks on a change and implements the pcg
+// command line tool.
+//
+// It can be used to build a binary embedding pcg with additional checks:
+//
+//	func init() {
+//		checks.Register("proprietary", func() checks.Check { return &Proprietary{} })
+//	}
+//
+//	func main() {
+//		runner.Main(&runner.Options{Name: "mypcg"})
+//	}
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/pre-commit-go/scm"
+)
+
+// Options are the optional parameters of RunChecks and Main.
+type Options struct {
+	// Observer, if not nil, is notified of the checks and processes being run.
+	Observer checks.Observer
+	// PrereqReady, if not nil, is waited for before running the checks with
+	// prerequisites, e.g. while the prerequisites are being installed.
+	PrereqReady *sync.WaitGroup
+	// Name is the name of the binary run by the git hooks installed by Main,
+	// e.g. "mypcg" for a binary embedding pcg. It is only used by Main and
+	// defaults to "pcg".
+	Name string
+}
+
+// Result is the outcome of RunChecks.
+type Result struct {
+	// Errors are the errors of the checks that failed, plus the unused
+	// suppressions when checks.Options.FailOnUnusedSuppressions is set.
+	Errors []error
+	// Warnings are the checks that took longer than checks.Options.MaxDuration.
+	Warnings []error
+	// Skipped are the checks that were not run because a dependency failed, or
+	// that were canceled because another check failed with
+	// checks.Options.FailFast.
+	Skipped []checks.Check
+	// Duration is the time taken to run all the checks.
+	Duration time.Duration
+}
+
+// RunChecks runs concurrently the checks enabled in config for modes on
+// change.
+//
+// The checks listed in checks.Options.DependsOn are run first and a check is
+// skipped when one of its dependencies failed. With checks.Options.FailFast,
+// the other checks are canceled on the first failure.
+//
+// The checks and the processes they start are reported to opts.Observer. The
+// suppressions and the baseline in the repository are applied to the
+// checks' errors. The returned error is only for failures to load them or
+// for invalid dependencies; the checks' errors are in Result.Errors.
+func RunChecks(change scm.Change, config *checks.Config, modes []checks.Mode, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	enabledChecks, options := config.EnabledChecks(modes)
+	options.Observer = opts.Observer
+	if err := checkCycles(enabledChecks, options.DependsOn); err != nil {
+		return nil, err
+	}
+	suppressions, err := checks.LoadSuppressions(change)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := checks.LoadBaseline(change.Repo().Root())
+	if err != nil {
+		return nil, err
+	}
+	// running has the checks that didn't finish yet by name, so the checks
+	// depending on them can wait for them.
+	running := map[string]*sync.WaitGroup{}
+	for _, c := range enabledChecks {
+		if running[c.GetName()] == nil {
+			running[c.GetName()] = &sync.WaitGroup{}
+		}
+		running[c.GetName()].Add(1)
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	// failed has the names of the checks that failed or were skipped and
+	// canceled is set once a check failed with FailFast.
+	failed := map[string]bool{}
+	canceled := false
+	result := &Result{}
+	start := time.Now()
+	for _, c := range enabledChecks {
+		wg.Add(1)
+		go func(check checks.Check) {
+			defer wg.Done()
+			name := check.GetName()
+			defer running[name].Done()
+			for _, dep := range options.DependsOn[name] {
+				if w := running[dep]; w != nil {
+					w.Wait()
+				}
+			}
+			lock.Lock()
+			reason := ""
+			if canceled {
+				reason = "canceled"
+			} else {
+				for _, dep := range options.DependsOn[name] {
+					if failed[dep] {
+						reason = dep + " failed"
+						break
+					}
+				}
+			}
+			if reason != "" {
+				failed[name] = true
+				result.Skipped = append(result.Skipped, check)
+			}
+			lock.Unlock()
+			if reason != "" {
+				if opts.Observer != nil {
+					opts.Observer.CheckSkipped(check, reason)
+				}
+				return
+			}
+			if len(check.GetPrerequisites()) != 0 && opts.PrereqReady != nil {
+				// If this check has prerequisites, wait for all prerequisites to be
+				// checked for presence.
+				opts.PrereqReady.Wait()
+			}
+			if opts.Observer != nil {
+				opts.Observer.CheckStarted(check)
+			}
+			duration, err := callRun(check, change, options.ForCheck(check))
+			err = suppressions.Filter(name, err)
+			err = baseline.Filter(change, name, err)
+			lock.Lock()
+			wasCanceled := false
+			if err != nil {
+				failed[name] = true
+				if canceled {
+					// The failure is likely caused by the cancelation.
+					wasCanceled = true
+					result.Skipped = append(result.Skipped, check)
+				} else {
+					result.Errors = append(result.Errors, err)
+					if options.FailFast {
+						canceled = true
+						options.Cancel()
+					}
+				}
+			} else if max := time.Duration(options.MaxDuration) * time.Second; duration > max {
+				// A check that took too long is a check that failed.
+				result.Warnings = append(result.Warnings, fmt.Errorf("check %s took %1.2fs -> IT IS TOO SLOW (limit: %s)", name, duration.Seconds(), max))
+			}
+			lock.Unlock()
+			if opts.Observer != nil {
+				if wasCanceled {
+					opts.Observer.CheckSkipped(check, "canceled")
+				} else {
+					opts.Observer.CheckFinished(check, duration, err)
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+	if options.FailOnUnusedSuppressions && !canceled {
+		if unused := suppressions.Unused(); len(unused) != 0 {
+			result.Errors = append(result.Errors, fmt.Errorf("unused suppressions:\n%s", strings.Join(unused, "\n")))
+		}
+	}
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// Private stuff.
+
+func callRun(check checks.Check, change scm.Change, options *checks.Options) (time.Duration, error) {
+	start := time.Now()
+	err := check.Run(change, options)
+	return time.Now().Sub(start), err
+}
+
+// checkCycles returns an error if the dependencies between the enabled checks
+// have a cycle, which would deadlock.
+func checkCycles(enabledChecks []checks.Check, dependsOn map[string][]string) error {
+	enabled := map[string]bool{}
+	for _, c := range enabledChecks {
+		enabled[c.GetName()] = true
+	}
+	names := make([]string, 0, len(enabled))
+	for name := range enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// state is 1 while visiting the dependencies of a check, 2 once done.
+	state := map[string]int{}
+	var visit func(path []string) error
+	visit = func(path []string) error {
+		name := path[len(path)-1]
+		switch state[name] {
+		case 1:
+			for i := range path {
+				if path[i] == name {
+					path = path[i:]
+					break
+				}
+			}
+			return fmt.Errorf("depends_on has a cycle: %s", strings.Join(path, " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dep := range dependsOn[name] {
+			if enabled[dep] {
+				if err := visit(append(path, dep)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for _, name := range names {
+		if err := visit([]string{name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
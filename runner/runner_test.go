@@ -0,0 +1,260 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maruel/pre-commit-go/checks"
+	"github.com/maruel/pre-commit-go/internal"
+	"github.com/maruel/pre-commit-go/scm"
+	"github.com/maruel/ut"
+)
+
+func TestRunChecks(t *testing.T) {
+	t.Parallel()
+	change, cleanup := newChange(t)
+	defer cleanup()
+
+	config := &checks.Config{
+		Modes: map[checks.Mode]checks.Settings{
+			checks.PreCommit: {
+				Checks: checks.Checks{
+					"fake": {&fake{name: "pass"}, &fake{name: "fail", err: errors.New("failed")}},
+				},
+				Options: checks.Options{MaxDuration: 1},
+			},
+		},
+	}
+	o := &recorder{}
+	opts := &Options{Observer: o}
+	result, err := RunChecks(change, config, []checks.Mode{checks.PreCommit}, opts)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []error{errors.New("failed")}, result.Errors)
+	ut.AssertEqual(t, []error(nil), result.Warnings)
+	sort.Strings(o.events)
+	expected := []string{
+		"check finished fail",
+		"check finished pass",
+		"check started fail",
+		"check started pass",
+		"process finished pass 0",
+		"process started pass go version",
+	}
+	ut.AssertEqual(t, expected, o.events)
+}
+
+func TestRunChecksDependsOn(t *testing.T) {
+	t.Parallel()
+	change, cleanup := newChange(t)
+	defer cleanup()
+
+	config := &checks.Config{
+		Modes: map[checks.Mode]checks.Settings{
+			checks.PreCommit: {
+				Checks: checks.Checks{
+					"fake": {
+						&fake{name: "cheap", checkName: "cheap", err: errors.New("failed")},
+						&fake{name: "expensive", checkName: "expensive"},
+						&fake{name: "last", checkName: "last"},
+						&fake{name: "other", checkName: "other"},
+					},
+				},
+				Options: checks.Options{
+					MaxDuration: 60,
+					DependsOn: map[string][]string{
+						"expensive": {"cheap", "disabled"},
+						"last":      {"expensive"},
+						"other":     {"disabled"},
+					},
+				},
+			},
+		},
+	}
+	o := &recorder{}
+	result, err := RunChecks(change, config, []checks.Mode{checks.PreCommit}, &Options{Observer: o})
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []error{errors.New("failed")}, result.Errors)
+	ut.AssertEqual(t, 2, len(result.Skipped))
+	sort.Strings(o.events)
+	expected := []string{
+		"check finished cheap",
+		"check finished other",
+		"check skipped expensive: cheap failed",
+		"check skipped last: expensive failed",
+		"check started cheap",
+		"check started other",
+		"process finished other 0",
+		"process started other go version",
+	}
+	ut.AssertEqual(t, expected, o.events)
+}
+
+func TestRunChecksDependsOnCycle(t *testing.T) {
+	t.Parallel()
+	change, cleanup := newChange(t)
+	defer cleanup()
+
+	config := &checks.Config{
+		Modes: map[checks.Mode]checks.Settings{
+			checks.PreCommit: {
+				Checks: checks.Checks{
+					"fake": {&fake{checkName: "a"}, &fake{checkName: "b"}},
+				},
+				Options: checks.Options{DependsOn: map[string][]string{"a": {"b"}, "b": {"a"}}},
+			},
+		},
+	}
+	result, err := RunChecks(change, config, []checks.Mode{checks.PreCommit}, nil)
+	ut.AssertEqual(t, errors.New("depends_on has a cycle: a -> b -> a"), err)
+	ut.AssertEqual(t, (*Result)(nil), result)
+}
+
+func TestRunChecksFailFast(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available")
+	}
+	change, cleanup := newChange(t)
+	defer cleanup()
+
+	config := &checks.Config{
+		Modes: map[checks.Mode]checks.Settings{
+			checks.PreCommit: {
+				Checks: checks.Checks{
+					"fake": {
+						&fake{name: "fail", checkName: "fail", err: errors.New("failed")},
+						&fake{name: "slow", checkName: "slow", args: []string{"sleep", "60"}},
+					},
+				},
+				Options: checks.Options{MaxDuration: 60, FailFast: true},
+			},
+		},
+	}
+	result, err := RunChecks(change, config, []checks.Mode{checks.PreCommit}, nil)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, []error{errors.New("failed")}, result.Errors)
+	ut.AssertEqual(t, 1, len(result.Skipped))
+	ut.AssertEqual(t, "slow", result.Skipped[0].GetName())
+	ut.AssertEqual(t, true, result.Duration < 30*time.Second)
+}
+
+func TestCheckCycles(t *testing.T) {
+	t.Parallel()
+	enabled := []checks.Check{&fake{checkName: "a"}, &fake{checkName: "b"}, &fake{checkName: "c"}}
+	data := []struct {
+		dependsOn map[string][]string
+		expected  error
+	}{
+		{nil, nil},
+		{map[string][]string{"a": {"b", "c"}, "b": {"c"}}, nil},
+		{map[string][]string{"a": {"d"}, "d": {"a"}}, nil},
+		{map[string][]string{"a": {"a"}}, errors.New("depends_on has a cycle: a -> a")},
+		{map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}}, errors.New("depends_on has a cycle: b -> c -> b")},
+	}
+	for i, line := range data {
+		ut.AssertEqualIndex(t, i, line.expected, checkCycles(enabled, line.dependsOn))
+	}
+}
+
+// Private stuff.
+
+// newChange returns a change with a single new file in a new git repository.
+func newChange(t *testing.T) (scm.Change, func()) {
+	td, err := ioutil.TempDir("", "pre-commit-go")
+	ut.AssertEqual(t, nil, err)
+	cleanup := func() {
+		if err := internal.RemoveAll(td); err != nil {
+			t.Fail()
+		}
+	}
+	ut.AssertEqual(t, nil, ioutil.WriteFile(filepath.Join(td, "foo.go"), []byte("package foo\n"), 0600))
+	for _, args := range [][]string{{"git", "init"}, {"git", "add", "."}} {
+		out, code, err := internal.Capture(td, nil, args...)
+		ut.AssertEqualf(t, 0, code, out)
+		ut.AssertEqual(t, nil, err)
+	}
+	repo, err := scm.GetRepo(td, "")
+	ut.AssertEqual(t, nil, err)
+	change, err := repo.Between(scm.Current, scm.Initial, nil)
+	ut.AssertEqual(t, nil, err)
+	return change, cleanup
+}
+
+// fake is a check running args, "go version" by default, or failing with err.
+type fake struct {
+	name      string
+	checkName string
+	args      []string
+	err       error
+}
+
+func (f *fake) GetDescription() string { return f.name }
+
+func (f *fake) GetName() string {
+	if f.checkName != "" {
+		return f.checkName
+	}
+	return "fake"
+}
+
+func (f *fake) GetPrerequisites() []checks.CheckPrerequisite { return nil }
+
+func (f *fake) Run(change scm.Change, options *checks.Options) error {
+	if f.err != nil {
+		return f.err
+	}
+	args := f.args
+	if args == nil {
+		args = []string{"go", "version"}
+	}
+	_, _, _, err := options.Capture(change.Repo(), args...)
+	return err
+}
+
+// recorder is a checks.Observer recording the events.
+type recorder struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(s string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, s)
+}
+
+func (r *recorder) CheckStarted(check checks.Check) {
+	r.add("check started " + check.GetDescription())
+}
+
+func (r *recorder) CheckFinished(check checks.Check, duration time.Duration, err error) {
+	r.add("check finished " + check.GetDescription())
+}
+
+func (r *recorder) CheckSkipped(check checks.Check, reason string) {
+	r.add("check skipped " + check.GetDescription() + ": " + reason)
+}
+
+func (r *recorder) ProcessStarted(check checks.Check, id int, args []string) {
+	r.add("process started " + check.GetDescription() + " " + strings.Join(args, " "))
+}
+
+func (r *recorder) ProcessFinished(check checks.Check, id int, exitCode int, duration time.Duration) {
+	r.add(fmt.Sprintf("process finished %s %d", check.GetDescription(), exitCode))
+}
+
+func (r *recorder) PrereqInstalling(urls []string) {
+	r.add("prereq installing " + strings.Join(urls, " "))
+}
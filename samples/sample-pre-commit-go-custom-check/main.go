@@ -2,23 +2,57 @@
 // Use of this source code is governed under the Apache License, Version 2.0
 // that can be found in the LICENSE file.
 
+// sample-pre-commit-go-custom-check is a sample custom check using the json
+// protocol. It reports the TODO comments without an owner, e.g. "TODO: foo"
+// instead of "TODO(name): foo", in the modified Go files.
+//
+// Use it with a custom check with "protocol: 1", see the custom section of
+// CONFIGURATION.md.
 package main
 
 import (
+	"bufio"
 	"flag"
-	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/maruel/pre-commit-go/checks/protocol"
 )
 
-func mainImpl() error {
-	flag.Parse()
-	// TODO(maruel): Do something.
-	return nil
+// reTODO matches a TODO comment without owner.
+var reTODO = regexp.MustCompile(`//\s*TODO(?:[^(]|$)`)
+
+func check(req *protocol.Request) ([]protocol.Finding, error) {
+	var findings []protocol.Finding
+	for _, f := range req.Changed.GoFiles {
+		file, err := os.Open(filepath.Join(req.RepoRoot, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		s := bufio.NewScanner(file)
+		for line := 1; s.Scan(); line++ {
+			if loc := reTODO.FindStringIndex(s.Text()); loc != nil {
+				findings = append(findings, protocol.Finding{
+					Path:    f,
+					Line:    line,
+					Column:  loc[0] + 1,
+					Rule:    "todo-owner",
+					Message: "TODO without owner: " + strings.TrimSpace(s.Text()[loc[0]:]),
+				})
+			}
+		}
+		err = s.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return findings, nil
 }
 
 func main() {
-	if err := mainImpl(); err != nil {
-		fmt.Fprintf(os.Stderr, "sample-pre-commit-go-custom-check: %s\n", err)
-		os.Exit(1)
-	}
+	flag.Parse()
+	protocol.Main(check)
 }
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -26,6 +27,10 @@ type Change interface {
 	// Package returns the package name to reference Repo().Root(). Returns an
 	// empty string if the repository is located outside of $GOPATH.
 	Package() string
+	// Base returns the commit this change is compared against. It is Initial
+	// when the change includes the whole history, e.g. on continuous
+	// integration.
+	Base() Commit
 	// Changed is the directly affected files and packages.
 	Changed() Set
 	// Indirect returns the Set of everything affected indirectly, e.g. all
@@ -44,6 +49,12 @@ type Change interface {
 	// level and generated files (like proto-gen-go generated files) should be
 	// ignored.
 	IsIgnored(p string) bool
+	// IsGenerated returns true if this path is a generated file, as marked by a
+	// "// Code generated ... DO NOT EDIT." line before the package clause. See
+	// https://golang.org/s/generatedcode.
+	//
+	// Generated files are not ignored; each check decides whether to skip them.
+	IsGenerated(p string) bool
 }
 
 // Set is a subset of files/directories/packages relative to the change and the
@@ -51,6 +62,8 @@ type Change interface {
 //
 // Each list is guaranteed to be sorted according to sort.StringsAreStored().
 type Set interface {
+	// Files returns all the files, including the non Go files.
+	Files() []string
 	// GoFiles returns all the source files, including tests.
 	GoFiles() []string
 	// Packages returns all the packages included in this set, using the relative
@@ -65,6 +78,23 @@ type Set interface {
 	TestPackages() []string
 }
 
+// Subset returns the change restricted to the files and the packages in the
+// directories for which inDir returns true.
+//
+// inDir is called with directories relative to the repository root in POSIX
+// format, "." being the root. The files outside of the subset are ignored, so
+// the tools scanning the whole checkout can filter their output with
+// IsIgnored(). Everything else, e.g. Content(), is forwarded to c.
+func Subset(c Change, inDir func(dir string) bool) Change {
+	return &subset{
+		Change:   c,
+		inDir:    inDir,
+		direct:   filterSet(c.Changed(), inDir),
+		indirect: filterSet(c.Indirect(), inDir),
+		all:      filterSet(c.All(), inDir),
+	}
+}
+
 // Private details.
 
 const pathSeparator = string(os.PathSeparator)
@@ -72,6 +102,7 @@ const pathSeparator = string(os.PathSeparator)
 type change struct {
 	repo           ReadOnlyRepo
 	packageName    string
+	base           Commit
 	ignorePatterns IgnorePatterns
 	direct         set
 	indirect       set
@@ -93,6 +124,9 @@ func newChange(r ReadOnlyRepo, files, allFiles, ignorePatterns IgnorePatterns) *
 		ignorePatterns: ignorePatterns,
 		content:        map[string][]byte{},
 	}
+	c.direct.allFiles = files
+	c.indirect.allFiles = files
+	c.all.allFiles = allFiles
 
 	// Map of <relative directory> : <relative package>
 	testDirs := map[string]string{}
@@ -310,6 +344,10 @@ func (c *change) Package() string {
 	return c.packageName
 }
 
+func (c *change) Base() Commit {
+	return c.base
+}
+
 func (c *change) Changed() Set {
 	return &c.direct
 }
@@ -343,15 +381,24 @@ func (c *change) IsIgnored(p string) bool {
 	return c.ignorePatterns.Match(p)
 }
 
+func (c *change) IsGenerated(p string) bool {
+	return strings.HasSuffix(p, ".go") && isGenerated(c.Content(p))
+}
+
 // set implements Set.
 //
 // Items must be sorted.
 type set struct {
+	allFiles     []string
 	files        []string
 	packages     []string
 	testPackages []string
 }
 
+func (s *set) Files() []string {
+	return s.allFiles
+}
+
 func (s *set) GoFiles() []string {
 	return s.files
 }
@@ -364,6 +411,60 @@ func (s *set) TestPackages() []string {
 	return s.testPackages
 }
 
+// subset implements Change for Subset.
+type subset struct {
+	Change
+	inDir    func(dir string) bool
+	direct   set
+	indirect set
+	all      set
+}
+
+func (s *subset) Changed() Set {
+	return &s.direct
+}
+
+func (s *subset) Indirect() Set {
+	return &s.indirect
+}
+
+func (s *subset) All() Set {
+	return &s.all
+}
+
+func (s *subset) IsIgnored(p string) bool {
+	return !s.inDir(filepath.ToSlash(dirName(p))) || s.Change.IsIgnored(p)
+}
+
+// filterSet returns the items of s in the directories for which inDir
+// returns true.
+func filterSet(s Set, inDir func(dir string) bool) set {
+	files := func(l []string) []string {
+		var out []string
+		for _, f := range l {
+			if inDir(filepath.ToSlash(dirName(f))) {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+	packages := func(l []string) []string {
+		var out []string
+		for _, p := range l {
+			if inDir(strings.TrimPrefix(p, "./")) {
+				out = append(out, p)
+			}
+		}
+		return out
+	}
+	return set{
+		allFiles:     files(s.Files()),
+		files:        files(s.GoFiles()),
+		packages:     packages(s.Packages()),
+		testPackages: packages(s.TestPackages()),
+	}
+}
+
 func dirToPkg(d string) string {
 	if d == "." {
 		return d
@@ -472,3 +573,24 @@ outer:
 	}
 	return pkgName, imports
 }
+
+// reGenerated matches the comment marking a generated file.
+var reGenerated = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated returns true if content has the comment marking a generated file
+// before the package clause.
+func isGenerated(content []byte) bool {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(content))
+	s.Init(file, content, nil, scanner.ScanComments)
+	for {
+		_, tok, lit := s.Scan()
+		if tok != token.COMMENT {
+			return false
+		}
+		if reGenerated.MatchString(strings.TrimRight(lit, "\r")) {
+			return true
+		}
+	}
+}
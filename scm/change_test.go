@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/maruel/pre-commit-go/internal"
@@ -67,6 +68,24 @@ func TestChangIgnore(t *testing.T) {
 	ut.AssertEqual(t, true, c.IsIgnored("bar/foo.pb.go"))
 }
 
+func TestIsGenerated(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		content  string
+		expected bool
+	}{
+		{"// Code generated by stringer. DO NOT EDIT.\n\npackage foo\n", true},
+		{"// Copyright 2016 Foo.\n\n// Code generated by protoc-gen-go. DO NOT EDIT.\r\n/* doc */\npackage foo\n", true},
+		{"package foo\n\n// Code generated by stringer. DO NOT EDIT.\n", false},
+		{"// Code generated by stringer. DO NOT EDIT\npackage foo\n", false},
+		{"/* Code generated by stringer. DO NOT EDIT. */\npackage foo\n", false},
+		{"", false},
+	}
+	for i, line := range data {
+		ut.AssertEqualIndex(t, i, line.expected, isGenerated([]byte(line.content)))
+	}
+}
+
 var commonTree = map[string]string{
 	"bar/bar.go":      "package bar\nfunc Bar() int { return 1}",
 	"bar/bar_test.go": "package bar",
@@ -193,6 +212,44 @@ func TestChangeAll(t *testing.T) {
 	ut.AssertEqual(t, []string{".", "./bar"}, all.TestPackages())
 }
 
+func TestSubset(t *testing.T) {
+	t.Parallel()
+	root, allFiles, cleanup := makeTree(t,
+		map[string]string{
+			"bar/bar.go":        "package bar\nfunc Bar() int { return 1}",
+			"bar/bar_test.go":   "package bar",
+			"bar/baz/baz.go":    "package baz",
+			"bar/baz/README.md": "baz",
+			"barfoo/barfoo.go":  "package barfoo",
+			"foo/foo.go":        "package foo\nfunc Foo() int { return 42}",
+			"main.go":           "package main",
+			"main_test.go":      "package main",
+		})
+	defer cleanup()
+	r := &dummyRepo{t, root}
+	c := newChange(r, []string{"bar/bar.go", "bar/baz/README.md", "bar/baz/baz.go", "barfoo/barfoo.go", "main.go"}, allFiles, nil)
+	s := Subset(c, func(dir string) bool {
+		return (dir == "bar" || strings.HasPrefix(dir, "bar/")) && dir != "bar/baz"
+	})
+	ut.AssertEqual(t, r, s.Repo())
+	changed := s.Changed()
+	ut.AssertEqual(t, []string{"bar/bar.go"}, changed.Files())
+	ut.AssertEqual(t, []string{"bar/bar.go"}, changed.GoFiles())
+	ut.AssertEqual(t, []string{"./bar"}, changed.Packages())
+	ut.AssertEqual(t, []string{"./bar"}, changed.TestPackages())
+	all := s.All()
+	ut.AssertEqual(t, []string{"bar/bar.go", "bar/bar_test.go"}, all.GoFiles())
+	ut.AssertEqual(t, []string{"./bar"}, all.Packages())
+	ut.AssertEqual(t, false, s.IsIgnored(filepath.Join("bar", "bar.go")))
+	ut.AssertEqual(t, true, s.IsIgnored(filepath.Join("bar", "baz", "baz.go")))
+	ut.AssertEqual(t, true, s.IsIgnored("main.go"))
+
+	s = Subset(c, func(dir string) bool { return dir == "." })
+	ut.AssertEqual(t, []string{"main.go"}, s.Changed().GoFiles())
+	ut.AssertEqual(t, []string{"."}, s.Changed().Packages())
+	ut.AssertEqual(t, []string{"."}, s.All().TestPackages())
+}
+
 func TestGetImports(t *testing.T) {
 	t.Parallel()
 	data := []struct {
@@ -316,6 +373,14 @@ func (d *dummyRepo) Between(recent, old Commit, ignoredPaths IgnorePatterns) (Ch
 	d.t.FailNow()
 	return nil, nil
 }
+func (d *dummyRepo) Export(c Commit, dst string) error {
+	d.t.FailNow()
+	return nil
+}
+func (d *dummyRepo) Ignored(files []string) []string {
+	d.t.FailNow()
+	return nil
+}
 func (d *dummyRepo) GOPATH() string { return d.root }
 
 // makeTree creates a temporary directory and creates the files in it.
@@ -8,6 +8,7 @@ package scm
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -74,6 +75,17 @@ type ReadOnlyRepo interface {
 	//
 	// Returns nil and no error if there's no file difference.
 	Between(recent, old Commit, ignorePatterns IgnorePatterns) (Change, error)
+	// Export writes a copy of the tree at commit c into directory dst, which
+	// must exist. Current copies the tracked files as found on the file system,
+	// including their uncommitted changes. Initial writes nothing.
+	//
+	// This is useful to run tools against another version of the tree without
+	// touching the checkout.
+	Export(c Commit, dst string) error
+	// Ignored returns the files that are not tracked and are ignored by the
+	// source control, e.g. listed in a .gitignore file. The files are relative
+	// to Root() and don't have to exist.
+	Ignored(files []string) []string
 	// GOPATH returns the GOPATH. Mostly used in tests.
 	GOPATH() string
 }
@@ -90,6 +102,9 @@ type Repo interface {
 	Restore() error
 	// Checkout checks out a commit or a branch.
 	Checkout(refish string) error
+	// Add stages the current content of the files, relative to Root(), in the
+	// index.
+	Add(files []string) error
 }
 
 // GetRepo returns a valid Repo if one is found.
@@ -348,7 +363,66 @@ func (g *git) Between(recent, old Commit, ignorePatterns IgnorePatterns) (Change
 	sort.Strings(allFiles)
 	wg.Wait()
 
-	return newChange(g, files, allFiles, ignorePatterns), nil
+	c := newChange(g, files, allFiles, ignorePatterns)
+	if gold == gitInitial {
+		c.base = Initial
+	} else {
+		c.base = g.Eval(string(gold))
+	}
+	return c, nil
+}
+
+func (g *git) Export(c Commit, dst string) error {
+	gc := toGitCommit(c)
+	switch gc {
+	case gitInvalid:
+		return errors.New("invalid commit")
+	case gitInitial:
+		return nil
+	case gitCurrent:
+		files := g.captureList(nil, "ls-files", "-z")
+		if files == nil {
+			return errors.New("failed to list files")
+		}
+		for _, f := range files {
+			if err := copyFile(filepath.Join(g.root, f), filepath.Join(dst, f)); err != nil {
+				if os.IsNotExist(err) {
+					// Tracked file deleted from the checkout.
+					continue
+				}
+				return err
+			}
+		}
+		return nil
+	}
+	f, err := ioutil.TempFile("", "pre-commit-go")
+	if err != nil {
+		return err
+	}
+	tarPath := f.Name()
+	f.Close()
+	defer internal.Remove(tarPath)
+	if out, e, err := g.capture("archive", "--format=tar", "-o", tarPath, string(gc)); e != 0 || err != nil {
+		return fmt.Errorf("archive failed:\n%s", out)
+	}
+	return untar(tarPath, dst)
+}
+
+func (g *git) Ignored(files []string) []string {
+	if len(files) == 0 {
+		return nil
+	}
+	stdin := strings.Join(files, "\x00") + "\x00"
+	out, _, code, err := internal.CaptureWithInput(nil, g.root, nil, []byte(stdin), "git", "check-ignore", "--stdin", "-z")
+	// check-ignore exits with 1 when no file is ignored.
+	if code != 0 || err != nil {
+		return nil
+	}
+	var list []string
+	for _, f := range strings.Split(strings.TrimSuffix(out, "\x00"), "\x00") {
+		list = append(list, filepath.FromSlash(f))
+	}
+	return list
 }
 
 func (g *git) GOPATH() string {
@@ -440,6 +514,16 @@ func (g *git) Checkout(refish string) error {
 	return nil
 }
 
+func (g *git) Add(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	if out, e, err := g.capture(append([]string{"add", "--"}, files...)...); e != 0 || err != nil {
+		return fmt.Errorf("add failed:\n%s", out)
+	}
+	return nil
+}
+
 func (g *git) untracked() []string {
 	return g.captureList(nil, "ls-files", "--others", "--exclude-standard", "-z")
 }
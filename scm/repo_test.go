@@ -222,6 +222,97 @@ func TestGetRepoGitSlowFailures(t *testing.T) {
 	}
 }
 
+func TestGetRepoGitSlowExport(t *testing.T) {
+	t.Parallel()
+	tmpDir, err := ioutil.TempDir("", "pre-commit-go")
+	defer func() {
+		if err := internal.RemoveAll(tmpDir); err != nil {
+			t.Errorf("%s", err)
+		}
+	}()
+	repoDir := filepath.Join(tmpDir, "repo")
+	ut.AssertEqual(t, nil, os.Mkdir(repoDir, 0700))
+	setup(t, repoDir)
+	r, err := getRepo(repoDir, tmpDir)
+	ut.AssertEqual(t, nil, err)
+
+	write(t, repoDir, "foo/file1.go", "package foo\n")
+	run(t, repoDir, nil, "add", "foo/file1.go")
+	deterministicCommit(t, repoDir)
+	write(t, repoDir, "foo/file1.go", "package foo\n// hello\n")
+	write(t, repoDir, "untracked.go", "package main\n")
+
+	c, err := r.Between(Current, Head, nil)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, r.Eval(string(Head)), c.Base())
+	c, err = r.Between(Current, Initial, nil)
+	ut.AssertEqual(t, nil, err)
+	ut.AssertEqual(t, Initial, c.Base())
+
+	head := filepath.Join(tmpDir, "head")
+	ut.AssertEqual(t, nil, os.Mkdir(head, 0700))
+	ut.AssertEqual(t, nil, r.Export(Head, head))
+	ut.AssertEqual(t, "package foo\n", read(t, head, "foo/file1.go"))
+
+	current := filepath.Join(tmpDir, "current")
+	ut.AssertEqual(t, nil, os.Mkdir(current, 0700))
+	ut.AssertEqual(t, nil, r.Export(Current, current))
+	ut.AssertEqual(t, "package foo\n// hello\n", read(t, current, "foo/file1.go"))
+	_, err = os.Stat(filepath.Join(current, "untracked.go"))
+	ut.AssertEqual(t, true, os.IsNotExist(err))
+
+	ut.AssertEqual(t, errors.New("invalid commit"), r.Export(Invalid, current))
+}
+
+func TestGetRepoGitSlowIgnored(t *testing.T) {
+	t.Parallel()
+	tmpDir, err := ioutil.TempDir("", "pre-commit-go")
+	defer func() {
+		if err := internal.RemoveAll(tmpDir); err != nil {
+			t.Errorf("%s", err)
+		}
+	}()
+	setup(t, tmpDir)
+	r, err := getRepo(tmpDir, tmpDir)
+	ut.AssertEqual(t, nil, err)
+
+	write(t, tmpDir, ".gitignore", "*.out\n")
+	write(t, tmpDir, "foo/tracked.out", "tracked\n")
+	run(t, tmpDir, nil, "add", ".gitignore")
+	run(t, tmpDir, nil, "add", "-f", "foo/tracked.out")
+	deterministicCommit(t, tmpDir)
+
+	files := []string{filepath.Join("foo", "bar.out"), filepath.Join("foo", "bar.go"), filepath.Join("foo", "tracked.out")}
+	ut.AssertEqual(t, []string{filepath.Join("foo", "bar.out")}, r.Ignored(files))
+	ut.AssertEqual(t, []string(nil), r.Ignored(files[1:]))
+	ut.AssertEqual(t, []string(nil), r.Ignored(nil))
+}
+
+func TestGetRepoGitSlowAdd(t *testing.T) {
+	t.Parallel()
+	tmpDir, err := ioutil.TempDir("", "pre-commit-go")
+	defer func() {
+		if err := internal.RemoveAll(tmpDir); err != nil {
+			t.Errorf("%s", err)
+		}
+	}()
+	setup(t, tmpDir)
+	r, err := getRepo(tmpDir, "")
+	ut.AssertEqual(t, nil, err)
+
+	write(t, tmpDir, "file1.go", "package foo\n")
+	write(t, tmpDir, "file2.go", "package foo\n")
+	run(t, tmpDir, nil, "add", "file1.go")
+	deterministicCommit(t, tmpDir)
+	write(t, tmpDir, "file1.go", "package foo\n// hello\n")
+	check(t, r, []string{"file2.go"}, []string{"file1.go"})
+
+	ut.AssertEqual(t, nil, r.Add(nil))
+	ut.AssertEqual(t, nil, r.Add([]string{"file1.go", "file2.go"}))
+	check(t, r, []string{}, []string{})
+	ut.AssertEqual(t, true, r.Add([]string{"missing.go"}) != nil)
+}
+
 // Private stuff.
 
 func setup(t *testing.T, tmpDir string) {
@@ -5,7 +5,10 @@
 package scm
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
 )
@@ -32,3 +35,80 @@ func relToGOPATH(p, gopath string) (string, error) {
 	}
 	return "", fmt.Errorf("failed to find GOPATH relative directory for %s", p)
 }
+
+// copyFile copies a file, creating the destination directory as needed.
+func copyFile(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		l, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(l, dst)
+	}
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return writeFile(dst, s, fi.Mode().Perm())
+}
+
+// untar extracts the tar archive at p into dst.
+func untar(p, dst string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dst, filepath.FromSlash(h.Name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+				return err
+			}
+			if err := os.Symlink(h.Linkname, name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+				return err
+			}
+			if err := writeFile(name, r, os.FileMode(h.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+		// Ignore pax headers and anything else git may emit.
+	}
+}
+
+func writeFile(dst string, r io.Reader, mode os.FileMode) error {
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(d, r); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}